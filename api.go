@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// registerAPIHandlers registers the JSON API on mux.
+func registerAPIHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/v1/cheapest", cheapestHandler)
+	mux.HandleFunc("GET /api/v1/plan", planHandler)
+}
+
+// findTimeOfUse returns the live time of use with the given name, and its
+// location.
+func findTimeOfUse(name string) (timeOfUse, *time.Location, error) {
+	for _, tou := range getLiveConfig().TimeOfUse {
+		if tou.Name != name {
+			continue
+		}
+		loc, err := loadLocation(tou.Timezone)
+		if err != nil {
+			return timeOfUse{}, nil, err
+		}
+		return tou, loc, nil
+	}
+	return timeOfUse{}, nil, fmt.Errorf(`Unknown time of use "%s"`, name)
+}
+
+// durationParam parses an optional duration query parameter.
+func durationParam(r *http.Request, key string, def time.Duration) (time.Duration, error) {
+	v := r.URL.Query().Get(key)
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf(`Invalid %s. Must be a duration such as 3h. Got: "%s"`, key, v)
+	}
+	return d, nil
+}
+
+// timeParam parses an optional RFC 3339 time query parameter.
+func timeParam(r *http.Request, key string, def time.Time) (time.Time, error) {
+	v := r.URL.Query().Get(key)
+	if v == "" {
+		return def, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf(`Invalid %s. Must be an RFC 3339 time. Got: "%s"`, key, v)
+	}
+	return t, nil
+}
+
+// floatParam parses an optional number query parameter.
+func floatParam(r *http.Request, key string, def float64) (float64, error) {
+	v := r.URL.Query().Get(key)
+	if v == "" {
+		return def, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, fmt.Errorf(`Invalid %s. Must be a number. Got: "%s"`, key, v)
+	}
+	return f, nil
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("Error writing API response", "err", err)
+	}
+}
+
+// cheapestHandler finds the cheapest contiguous block of time in the schedule
+// of a time of use.
+func cheapestHandler(w http.ResponseWriter, r *http.Request) {
+	tou, loc, err := findTimeOfUse(r.URL.Query().Get("name"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	duration, err := durationParam(r, "duration", 0)
+	if err == nil && duration <= 0 {
+		err = fmt.Errorf(`Invalid duration. Must be positive. Got: "%s"`, r.URL.Query().Get("duration"))
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	within, err := durationParam(r, "within", defaultCheapestWithin)
+	if err == nil && within > maxCheapestWithin {
+		err = fmt.Errorf(`Invalid within. Must be at most %s. Got: "%s"`, maxCheapestWithin, r.URL.Query().Get("within"))
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	notBefore, err := timeParam(r, "not_before", time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	end := notBefore.Add(within)
+	deadline, err := timeParam(r, "deadline", end)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if deadline.Before(end) {
+		end = deadline
+	}
+
+	block, err := findCheapestBlock(tou, notBefore.In(loc), end, duration)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, block)
+}
+
+// planHandler plans the cheapest slots to run a flexible load in against the
+// schedule of a time of use.
+func planHandler(w http.ResponseWriter, r *http.Request) {
+	tou, loc, err := findTimeOfUse(r.URL.Query().Get("name"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	req, err := planRequestParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.NotBefore = req.NotBefore.In(loc)
+
+	p, err := planLoad(tou, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, p)
+}
+
+// planRequestParams parses a plan request from query parameters.
+func planRequestParams(r *http.Request) (planRequest, error) {
+	var req planRequest
+	var err error
+	if req.Runtime, err = durationParam(r, "runtime", 0); err != nil {
+		return planRequest{}, err
+	}
+	if req.Slot, err = durationParam(r, "slot", defaultPlanSlot); err != nil {
+		return planRequest{}, err
+	}
+	if req.MinRun, err = durationParam(r, "min_run", 0); err != nil {
+		return planRequest{}, err
+	}
+	if req.Power, err = floatParam(r, "power", 1); err != nil {
+		return planRequest{}, err
+	}
+	if req.NotBefore, err = timeParam(r, "not_before", time.Now()); err != nil {
+		return planRequest{}, err
+	}
+	if req.Deadline, err = timeParam(r, "deadline", req.NotBefore.Add(defaultCheapestWithin)); err != nil {
+		return planRequest{}, err
+	}
+	req = req.withDefaults()
+	return req, validatePlanRequest(req)
+}
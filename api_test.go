@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheapestHandler(t *testing.T) {
+	defer setLiveConfig(getLiveConfig())
+	setLiveConfig(config{TimeOfUse: []timeOfUse{cheapestTestTOU}})
+
+	mux := http.NewServeMux()
+	registerAPIHandlers(mux)
+
+	tests := []struct {
+		query  string
+		status int
+	}{
+		{"name=cheapest_test&duration=3h&not_before=2023-12-01T12:00:00Z", http.StatusOK},
+		{"name=unknown&duration=3h", http.StatusNotFound},
+		{"name=cheapest_test", http.StatusBadRequest},
+		{"name=cheapest_test&duration=3h&within=2160h", http.StatusBadRequest},
+		{"name=cheapest_test&duration=3h&within=169h", http.StatusBadRequest},
+		{"name=cheapest_test&duration=3h&not_before=today", http.StatusBadRequest},
+		{"name=cheapest_test&duration=3h&not_before=2023-12-01T12:00:00Z&deadline=2023-12-01T13:00:00Z", http.StatusBadRequest},
+	}
+	for _, tc := range tests {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/cheapest?"+tc.query, nil))
+		assert.Equal(t, tc.status, rec.Code, tc.query)
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/cheapest?name=cheapest_test&duration=3h&not_before=2023-12-01T12:00:00Z&deadline=2023-12-02T02:00:00Z", nil))
+	var result cheapestResult
+	if assert.NoError(t, json.NewDecoder(rec.Body).Decode(&result)) {
+		assert.Equal(t, "cheapest_test", result.Name)
+		assert.True(t, time.Date(2023, 12, 1, 23, 0, 0, 0, time.UTC).Equal(result.Start))
+		assert.True(t, time.Date(2023, 12, 2, 2, 0, 0, 0, time.UTC).Equal(result.End))
+		assert.InDelta(t, 0.5/3, result.AverageValue, 1e-12)
+	}
+}
+
+func TestPlanHandler(t *testing.T) {
+	defer setLiveConfig(getLiveConfig())
+	setLiveConfig(config{TimeOfUse: []timeOfUse{plannerTestTOU}})
+
+	mux := http.NewServeMux()
+	registerAPIHandlers(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/plan?name=planner_test&runtime=3h&slot=1h&power=2&not_before=2023-12-01T00:00:00Z&deadline=2023-12-01T12:00:00Z", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var p plan
+	if assert.NoError(t, json.NewDecoder(rec.Body).Decode(&p)) {
+		assert.Equal(t, []int{1, 3, 5}, planStarts(p))
+		assert.InDelta(t, 0.5, p.Cost, 1e-12)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/plan?name=planner_test&runtime=3h&power=lots", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "Invalid power. Must be a number. Got: \"lots\"\n", rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/plan?name=planner_test&slot=1m&runtime=72h&min_run=2h&not_before=2023-12-01T00:00:00Z&deadline=2023-12-08T00:00:00Z", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "Plans can have at most 2016 slots before the deadline. Got: 10080 slots of 1m0s\n", rec.Body.String())
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+)
+
+// bounds returns the start and end of the billing period containing now, at
+// midnight in the timezone of the billing cycle.
+func (bc billingCycle) bounds(now time.Time) (time.Time, time.Time) {
+	loc, err := loadLocation(bc.Timezone)
+	if err != nil {
+		slog.Error("error loading timezone. This should never error as TZ are validated on config load", "err", err, "timezone", bc.Timezone)
+		return bc.billingPeriod.bounds(now)
+	}
+	return bc.billingPeriod.bounds(now.In(loc))
+}
+
+// bounds returns the start and end of the billing period containing now, at
+// midnight in the location of now. Expects a validated billing period.
+func (b billingPeriod) bounds(now time.Time) (time.Time, time.Time) {
+	if b.Anchor != "" {
+		anchor, _ := time.Parse(time.DateOnly, b.Anchor)
+		days := daysBetween(anchor, now)
+		periods := days / b.LengthDays
+		if days < 0 && days%b.LengthDays != 0 {
+			periods--
+		}
+
+		start := time.Date(anchor.Year(), anchor.Month(), anchor.Day()+periods*b.LengthDays, 0, 0, 0, 0, now.Location())
+		return start, start.AddDate(0, 0, b.LengthDays)
+	}
+
+	start := b.monthlyStart(now.Year(), now.Month(), now.Location())
+	if now.Before(start) {
+		start = b.monthlyStart(now.Year(), now.Month()-1, now.Location())
+	}
+	return start, b.monthlyStart(start.Year(), start.Month()+1, now.Location())
+}
+
+// monthlyStart returns the start of the period starting in the given month.
+func (b billingPeriod) monthlyStart(year int, month time.Month, loc *time.Location) time.Time {
+	day := min(b.StartDay, daysInMonth(year, month))
+	return time.Date(year, month, day, 0, 0, 0, 0, loc)
+}
+
+// daysInMonth returns the number of days in a month, normalising months
+// outside of 1-12.
+func daysInMonth(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// daysBetween returns the number of calendar days from the date of a to the
+// date of b, each in their own location. Daylight saving doesn't affect the
+// result.
+func daysBetween(a, b time.Time) int {
+	from := time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	to := time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	return int(to.Sub(from).Hours() / 24)
+}
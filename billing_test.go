@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBillingPeriodBounds(t *testing.T) {
+	auckland, err := time.LoadLocation("Pacific/Auckland")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := map[string]struct {
+		period        billingPeriod
+		now           time.Time
+		expectedStart time.Time
+		expectedEnd   time.Time
+	}{
+		"start day, after start": {
+			period:        billingPeriod{StartDay: 15},
+			now:           time.Date(2024, 3, 20, 12, 0, 0, 0, auckland),
+			expectedStart: time.Date(2024, 3, 15, 0, 0, 0, 0, auckland),
+			expectedEnd:   time.Date(2024, 4, 15, 0, 0, 0, 0, auckland),
+		},
+		"start day, before start": {
+			period:        billingPeriod{StartDay: 15},
+			now:           time.Date(2024, 1, 14, 23, 59, 0, 0, auckland),
+			expectedStart: time.Date(2023, 12, 15, 0, 0, 0, 0, auckland),
+			expectedEnd:   time.Date(2024, 1, 15, 0, 0, 0, 0, auckland),
+		},
+		"start day, short month": {
+			period:        billingPeriod{StartDay: 31},
+			now:           time.Date(2024, 3, 1, 0, 0, 0, 0, auckland),
+			expectedStart: time.Date(2024, 2, 29, 0, 0, 0, 0, auckland),
+			expectedEnd:   time.Date(2024, 3, 31, 0, 0, 0, 0, auckland),
+		},
+		"anchor": {
+			period:        billingPeriod{Anchor: "2024-01-01", LengthDays: 28},
+			now:           time.Date(2024, 3, 1, 0, 0, 0, 0, auckland),
+			expectedStart: time.Date(2024, 2, 26, 0, 0, 0, 0, auckland),
+			expectedEnd:   time.Date(2024, 3, 25, 0, 0, 0, 0, auckland),
+		},
+		"anchor in the future": {
+			period:        billingPeriod{Anchor: "2024-01-01", LengthDays: 28},
+			now:           time.Date(2023, 12, 31, 0, 0, 0, 0, auckland),
+			expectedStart: time.Date(2023, 12, 4, 0, 0, 0, 0, auckland),
+			expectedEnd:   time.Date(2024, 1, 1, 0, 0, 0, 0, auckland),
+		},
+		"anchor across daylight saving": {
+			period:        billingPeriod{Anchor: "2023-09-01", LengthDays: 30},
+			now:           time.Date(2023, 10, 1, 0, 30, 0, 0, auckland),
+			expectedStart: time.Date(2023, 10, 1, 0, 0, 0, 0, auckland),
+			expectedEnd:   time.Date(2023, 10, 31, 0, 0, 0, 0, auckland),
+		},
+	}
+
+	for name, tc := range testCases {
+		start, end := tc.period.bounds(tc.now)
+		assert.Equal(t, tc.expectedStart, start, name)
+		assert.Equal(t, tc.expectedEnd, end, name)
+	}
+}
+
+func TestValidateBillingCycle(t *testing.T) {
+	power := billingCycle{Name: "power", billingPeriod: billingPeriod{StartDay: 15}}
+	assert.NoError(t, validateBillingCycle(power, nil))
+	assert.Equal(t, errors.New("Billing cycles must have a name"), validateBillingCycle(billingCycle{}, nil))
+	assert.Equal(t, errors.New(`Duplicate billing cycle name "power"`), validateBillingCycle(power, []billingCycle{power}))
+	assert.Equal(t, errors.New("Billing periods can have either start_day, or anchor and length_days, not both"),
+		validateBillingCycle(billingCycle{Name: "water", billingPeriod: billingPeriod{StartDay: 1, Anchor: "2024-01-01"}}, nil))
+}
+
+func TestValidateBillingPeriod(t *testing.T) {
+	assert.NoError(t, validateBillingPeriod(billingPeriod{StartDay: 31}))
+	assert.NoError(t, validateBillingPeriod(billingPeriod{Anchor: "2024-01-01", LengthDays: 30}))
+	assert.Equal(t, errors.New("Billing periods can have either start_day, or anchor and length_days, not both"),
+		validateBillingPeriod(billingPeriod{StartDay: 1, Anchor: "2024-01-01"}))
+	assert.Equal(t, errors.New(`Invalid billing period anchor. Must be YYYY-MM-DD. Got: "1/1/2024"`),
+		validateBillingPeriod(billingPeriod{Anchor: "1/1/2024", LengthDays: 30}))
+	assert.Equal(t, errors.New("Billing period length_days must be at least 1. Got: 0"),
+		validateBillingPeriod(billingPeriod{Anchor: "2024-01-01"}))
+	assert.Equal(t, errors.New("Billing period start_day must be 1-31. Got: 0"), validateBillingPeriod(billingPeriod{}))
+}
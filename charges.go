@@ -0,0 +1,120 @@
+package main
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// Accrued fixed charges by name, since the exporter started
+	fixedChargeAccruals   = map[string]*fixedChargeAccrual{}
+	fixedChargeAccrualsMu sync.Mutex
+)
+
+// Label of the period fixed charges are prorated over
+const fixedChargePeriodLabel = "period"
+
+type fixedChargeAccrual struct {
+	last    time.Time
+	accrued float64
+}
+
+// fixedChargePeriodBounds returns the start and end of the period containing
+// now, in the location of now, or of the billing cycle used as the period.
+func fixedChargePeriodBounds(fc fixedCharge, now time.Time) (time.Time, time.Time) {
+	switch fc.Period {
+	case "month":
+		start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		return start, start.AddDate(0, 1, 0)
+	case "billing_period":
+		if fc.cycle != nil {
+			return fc.cycle.bounds(now)
+		}
+		return fc.BillingPeriod.bounds(now)
+	}
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	return start, start.AddDate(0, 0, 1)
+}
+
+// fixedChargeRate returns the charge per second during the period containing
+// now. Periods vary in length, for example with daylight saving or months of
+// different lengths.
+func fixedChargeRate(fc fixedCharge, now time.Time) float64 {
+	start, end := fixedChargePeriodBounds(fc, now)
+	return fc.Amount / end.Sub(start).Seconds()
+}
+
+// fixedChargeAccrued returns the prorated charge between from and to.
+func fixedChargeAccrued(fc fixedCharge, from, to time.Time) float64 {
+	accrued := 0.0
+	for from.Before(to) {
+		_, end := fixedChargePeriodBounds(fc, from)
+		if end.After(to) {
+			end = to
+		}
+		accrued += fixedChargeRate(fc, from) * end.Sub(from).Seconds()
+		from = end
+	}
+	return accrued
+}
+
+// updateFixedChargeAccrual advances the accrued charge up to now, and returns
+// the total accrued since the exporter started.
+func updateFixedChargeAccrual(fc fixedCharge, now time.Time) float64 {
+	fixedChargeAccrualsMu.Lock()
+	defer fixedChargeAccrualsMu.Unlock()
+
+	state, ok := fixedChargeAccruals[fc.Name]
+	if !ok {
+		state = &fixedChargeAccrual{last: processStart}
+		fixedChargeAccruals[fc.Name] = state
+	}
+	if now.After(state.last) {
+		state.accrued += fixedChargeAccrued(fc, state.last.In(now.Location()), now)
+		state.last = now
+	}
+	return state.accrued
+}
+
+func describeFixedCharge(n metricNaming, fc fixedCharge) (*prometheus.Desc, *prometheus.Desc, *prometheus.Desc) {
+	labels := n.timezoneLabels(fc.Timezone)
+	labels[fixedChargePeriodLabel] = fc.Period
+	for k, v := range fc.Labels {
+		labels[k] = v
+	}
+
+	name := n.configuredMetricName(fc.Name)
+	return prometheus.NewDesc(name, fc.Description, nil, labels),
+		prometheus.NewDesc(name+"_rate_per_second", fc.Description+", prorated per second over the current period", nil, labels),
+		prometheus.NewDesc(name+"_accrued_total", fc.Description+", accrued since the exporter started", nil, labels)
+}
+
+func describeFixedCharges(ch chan<- *prometheus.Desc, c config) {
+	n := c.MetricNaming
+	for _, fc := range c.FixedCharges {
+		amount, rate, accrued := describeFixedCharge(n, fc)
+		ch <- amount
+		ch <- rate
+		ch <- accrued
+	}
+}
+
+func collectFixedCharges(ch chan<- prometheus.Metric, c config, utcNow time.Time) {
+	n := c.MetricNaming
+	for _, fc := range c.FixedCharges {
+		loc, err := loadLocation(fc.Timezone)
+		if err != nil {
+			slog.Error("error loading timezone. This should never error as TZ are validated on config load", "err", err, "timezone", fc.Timezone)
+			continue
+		}
+		now := utcNow.In(loc)
+
+		amount, rate, accrued := describeFixedCharge(n, fc)
+		ch <- prometheus.MustNewConstMetric(amount, prometheus.GaugeValue, fc.Amount)
+		ch <- prometheus.MustNewConstMetric(rate, prometheus.GaugeValue, fixedChargeRate(fc, now))
+		ch <- prometheus.MustNewConstMetric(accrued, prometheus.CounterValue, updateFixedChargeAccrual(fc, now))
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFixedChargeRate(t *testing.T) {
+	auckland, err := time.LoadLocation("Pacific/Auckland")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	daily := fixedCharge{Name: "daily", Amount: 2.4, Period: "day"}
+	assert.InDelta(t, 2.4/(24*60*60), fixedChargeRate(daily, time.Date(2023, 12, 1, 12, 0, 0, 0, auckland)), 1e-15)
+	assert.InDelta(t, 2.4/(23*60*60), fixedChargeRate(daily, time.Date(2023, 9, 24, 12, 0, 0, 0, auckland)), 1e-15,
+		"days shortened by daylight saving have a higher rate")
+
+	monthly := fixedCharge{Name: "monthly", Amount: 28, Period: "month"}
+	assert.Equal(t, 28.0/(29*24*60*60), fixedChargeRate(monthly, time.Date(2024, 2, 10, 0, 0, 0, 0, time.UTC)))
+
+	billing := fixedCharge{Name: "billing", Amount: 10, Period: "billing_period", BillingPeriod: &billingPeriod{Anchor: "2024-01-01", LengthDays: 10}}
+	assert.Equal(t, 1.0/(24*60*60), fixedChargeRate(billing, time.Date(2024, 2, 10, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestFixedChargeBillingCycleTimezone(t *testing.T) {
+	// A UTC charge using a Sydney billing cycle starts periods at midnight in
+	// Sydney, 13:00 UTC during daylight saving time
+	fc := fixedCharge{Name: "billing", Amount: 28, Period: "billing_period",
+		cycle: &billingCycle{Name: "power", Timezone: "Australia/Sydney", billingPeriod: billingPeriod{StartDay: 15}}}
+	start, end := fixedChargePeriodBounds(fc, time.Date(2024, 2, 14, 14, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2024, 2, 14, 13, 0, 0, 0, time.UTC), start.UTC())
+	assert.Equal(t, time.Date(2024, 3, 14, 13, 0, 0, 0, time.UTC), end.UTC())
+
+	// An hour in the 31 day period from 15 January, and an hour in the 29 day
+	// period from 15 February
+	accrued := fixedChargeAccrued(fc, time.Date(2024, 2, 14, 12, 0, 0, 0, time.UTC), time.Date(2024, 2, 14, 14, 0, 0, 0, time.UTC))
+	assert.InDelta(t, 28.0/(31*24)+28.0/(29*24), accrued, 1e-9)
+}
+
+func TestFixedChargeAccrued(t *testing.T) {
+	monthly := fixedCharge{Name: "monthly", Amount: 30, Period: "month"}
+
+	// Half of February, and a day of March
+	accrued := fixedChargeAccrued(monthly,
+		time.Date(2023, 2, 15, 0, 0, 0, 0, time.UTC),
+		time.Date(2023, 3, 2, 0, 0, 0, 0, time.UTC),
+	)
+	assert.InDelta(t, 15+30.0/31, accrued, 1e-9)
+}
+
+func TestCollectFixedCharges(t *testing.T) {
+	defer setLiveConfig(getLiveConfig())
+	setLiveConfig(config{FixedCharges: []fixedCharge{{
+		Name:        "supply_charge",
+		Description: "Daily supply charge",
+		Labels:      map[string]string{"provider": "Power Co"},
+		Amount:      2.4,
+		Period:      "day",
+	}}})
+	fixedChargeAccruals["supply_charge"] = &fixedChargeAccrual{last: time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC)}
+	defer delete(fixedChargeAccruals, "supply_charge")
+
+	testCh := make(chan prometheus.Metric)
+	go func() {
+		collectFixedCharges(testCh, getLiveConfig(), time.Date(2023, 12, 1, 12, 0, 0, 0, time.UTC))
+		close(testCh)
+	}()
+
+	values := map[string]float64{}
+	for m := range testCh {
+		actual := &dto.Metric{}
+		if err := m.Write(actual); err != nil {
+			t.Fatal(err)
+		}
+		for _, l := range actual.GetLabel() {
+			switch l.GetName() {
+			case "period":
+				assert.Equal(t, "day", l.GetValue())
+			case "provider":
+				assert.Equal(t, "Power Co", l.GetValue())
+			}
+		}
+		if actual.GetCounter() != nil {
+			values["accrued"] = actual.GetCounter().GetValue()
+		} else if strings.Contains(m.Desc().String(), "_rate_per_second") {
+			values["rate"] = actual.GetGauge().GetValue()
+		} else {
+			values["amount"] = actual.GetGauge().GetValue()
+		}
+	}
+
+	assert.Equal(t, 2.4, values["amount"])
+	assert.InDelta(t, 2.4/(24*60*60), values["rate"], 1e-15)
+	assert.InDelta(t, 1.2, values["accrued"], 1e-9)
+}
+
+func TestValidateFixedCharge(t *testing.T) {
+	assert.NoError(t, validateFixedCharge(fixedCharge{Name: "daily", Period: "day"}, nil))
+	assert.NoError(t, validateFixedCharge(fixedCharge{Name: "billing", Period: "billing_period", BillingPeriod: &billingPeriod{StartDay: 15}}, nil))
+	assert.Equal(t, errors.New("Fixed charges must have a name"), validateFixedCharge(fixedCharge{Period: "day"}, nil))
+	assert.Equal(t, errors.New(`Duplicate fixed charge name "daily"`),
+		validateFixedCharge(fixedCharge{Name: "daily", Period: "day"}, []fixedCharge{{Name: "daily", Period: "month"}}))
+	assert.Equal(t, errors.New("A billing_period or billing_cycle is required for a billing_period fixed charge"),
+		validateFixedCharge(fixedCharge{Name: "billing", Period: "billing_period"}, nil))
+	assert.Equal(t, errors.New(`Unknown fixed charge period "week". Must be day, month, or billing_period`),
+		validateFixedCharge(fixedCharge{Name: "weekly", Period: "week"}, nil))
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	defaultCheapestWithin = 24 * time.Hour
+	// Longest search for a cheapest block, which bounds the candidates to
+	// check
+	maxCheapestWithin = 7 * 24 * time.Hour
+)
+
+// cheapestResult is the cheapest contiguous block of time found in a schedule.
+type cheapestResult struct {
+	Name         string    `json:"name"`
+	Start        time.Time `json:"start"`
+	End          time.Time `json:"end"`
+	AverageValue float64   `json:"average_value"`
+}
+
+// findCheapestBlock finds the start of the contiguous block of duration d
+// between from and to with the lowest time weighted average value. Blocks
+// start on a breakpoint, end on a breakpoint, or start on a whole minute, so
+// the cheapest start within ramps and points is found to the minute. Ties go
+// to the earliest start. from should be in the timezone of the time of use.
+func findCheapestBlock(tou timeOfUse, from, to time.Time, d time.Duration) (cheapestResult, error) {
+	if d <= 0 {
+		return cheapestResult{}, errors.New("Duration must be positive")
+	}
+	if to.Sub(from) < d {
+		return cheapestResult{}, errors.New("No block of the duration fits between not_before and the end of the search")
+	}
+	segments := touSegments(tou, from, to)
+	last := to.Add(-d)
+
+	candidates := []time.Time{from, last}
+	for _, seg := range segments {
+		candidates = append(candidates, seg.start, seg.start.Add(-d))
+	}
+	for t := from.Truncate(time.Minute).Add(time.Minute); t.Before(last); t = t.Add(time.Minute) {
+		candidates = append(candidates, t)
+	}
+	candidates = slices.DeleteFunc(candidates, func(t time.Time) bool { return t.Before(from) || t.After(last) })
+	slices.SortFunc(candidates, func(a, b time.Time) int { return a.Compare(b) })
+
+	integrals := newRunningIntegral(segments)
+	best := cheapestResult{Name: tou.Name}
+	for i, start := range candidates {
+		avg := (integrals.until(start.Add(d)) - integrals.until(start)) / d.Seconds()
+		if i == 0 || avg < best.AverageValue-1e-9 {
+			best.Start, best.End, best.AverageValue = start, start.Add(d), avg
+		}
+	}
+	return best, nil
+}
+
+// segmentsIntegral returns the integral of the value of segments between from
+// and to, in value-seconds.
+func segmentsIntegral(segments []touSegment, from, to time.Time) float64 {
+	var integral float64
+	for _, seg := range segments {
+		start, end := seg.start, seg.end
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if !end.After(start) {
+			continue
+		}
+		integral += (segmentValueAt(seg, start) + segmentValueAt(seg, end)) / 2 * end.Sub(start).Seconds()
+	}
+	return integral
+}
+
+// runningIntegral is the integral of contiguous segments from the start of
+// the first segment, so the integral over any block is found with a binary
+// search rather than a scan of every segment.
+type runningIntegral struct {
+	segments []touSegment
+	// Integral up to the start of each segment, and the total
+	cumulative []float64
+}
+
+func newRunningIntegral(segments []touSegment) runningIntegral {
+	cumulative := make([]float64, len(segments)+1)
+	for i, seg := range segments {
+		cumulative[i+1] = cumulative[i] + (seg.startValue+seg.endValue)/2*seg.seconds()
+	}
+	return runningIntegral{segments: segments, cumulative: cumulative}
+}
+
+// until returns the integral from the start of the first segment to t, in
+// value-seconds.
+func (r runningIntegral) until(t time.Time) float64 {
+	i := sort.Search(len(r.segments), func(i int) bool { return r.segments[i].end.After(t) })
+	if i == len(r.segments) {
+		return r.cumulative[i]
+	}
+	seg := r.segments[i]
+	if !t.After(seg.start) {
+		return r.cumulative[i]
+	}
+	return r.cumulative[i] + (seg.startValue+segmentValueAt(seg, t))/2*t.Sub(seg.start).Seconds()
+}
+
+// segmentValueAt returns the value of a segment at a time within it.
+func segmentValueAt(seg touSegment, t time.Time) float64 {
+	if seg.seconds() == 0 {
+		return seg.startValue
+	}
+	return lerp(seg.startValue, seg.endValue, t.Sub(seg.start).Seconds()/seg.seconds())
+}
+
+func describeCheapestBlockMetric(n metricNaming, tou timeOfUse) *prometheus.Desc {
+	return prometheus.NewDesc(
+		n.touMetricName(tou)+"_next_cheapest_start_timestamp_seconds",
+		"Unix timestamp of the start of the cheapest block of "+tou.CheapestBlock.Duration.String()+" of "+tou.Name,
+		nil,
+		n.touConstLabels(tou),
+	)
+}
+
+func collectCheapestBlockMetric(ch chan<- prometheus.Metric, n metricNaming, tou timeOfUse, now time.Time) {
+	within := tou.CheapestBlock.Within
+	if within == 0 {
+		within = defaultCheapestWithin
+	}
+	block, err := findCheapestBlock(tou, now, now.Add(within), tou.CheapestBlock.Duration)
+	if err != nil {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(describeCheapestBlockMetric(n, tou), prometheus.GaugeValue, float64(block.Start.Unix()))
+}
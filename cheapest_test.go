@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+var cheapestTestTOU = timeOfUse{
+	Name:         "cheapest_test",
+	DefaultValue: 0.2,
+	TimeWindows: []timeWindow{
+		{Value: 0.1, startHour: 1, endHour: 3},
+		{Value: 0.05, startHour: 3, endHour: 4},
+		{Value: 0.3, startHour: 17, endHour: 21},
+	},
+}
+
+func TestFindCheapestBlock(t *testing.T) {
+	from := time.Date(2023, 12, 1, 12, 0, 0, 0, time.UTC)
+
+	block, err := findCheapestBlock(cheapestTestTOU, from, from.Add(24*time.Hour), 3*time.Hour)
+	if assert.NoError(t, err) {
+		assert.Equal(t, time.Date(2023, 12, 2, 1, 0, 0, 0, time.UTC), block.Start)
+		assert.Equal(t, time.Date(2023, 12, 2, 4, 0, 0, 0, time.UTC), block.End)
+		assert.InDelta(t, 0.25/3, block.AverageValue, 1e-12)
+	}
+
+	block, err = findCheapestBlock(cheapestTestTOU, from, from.Add(24*time.Hour), 30*time.Minute)
+	if assert.NoError(t, err) {
+		assert.Equal(t, time.Date(2023, 12, 2, 3, 0, 0, 0, time.UTC), block.Start, "should find the earliest of equally cheap blocks")
+	}
+
+	// Until 02:00, the cheapest block ends at the deadline
+	block, err = findCheapestBlock(cheapestTestTOU, from, time.Date(2023, 12, 2, 2, 0, 0, 0, time.UTC), 3*time.Hour)
+	if assert.NoError(t, err) {
+		assert.Equal(t, time.Date(2023, 12, 1, 23, 0, 0, 0, time.UTC), block.Start)
+		assert.InDelta(t, 0.5/3, block.AverageValue, 1e-12)
+	}
+
+	_, err = findCheapestBlock(cheapestTestTOU, from, from.Add(time.Hour), 3*time.Hour)
+	assert.Equal(t, errors.New("No block of the duration fits between not_before and the end of the search"), err)
+}
+
+func TestFindCheapestBlockPoints(t *testing.T) {
+	// A dip to 0 at 06:30, so the cheapest hour is centred on it
+	tou := timeOfUse{
+		Name:         "cheapest_test",
+		DefaultValue: 1,
+		TimeWindows: []timeWindow{{
+			startHour: 6, endHour: 7,
+			Points: []rampPoint{{Value: 1, hour: 6}, {Value: 0, hour: 6, minute: 30}, {Value: 1, hour: 7}},
+		}},
+	}
+	from := time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC)
+	block, err := findCheapestBlock(tou, from, from.Add(24*time.Hour), time.Hour)
+	if assert.NoError(t, err) {
+		assert.Equal(t, time.Date(2023, 12, 1, 6, 0, 0, 0, time.UTC), block.Start)
+		assert.InDelta(t, 0.5, block.AverageValue, 1e-12)
+	}
+}
+
+func TestSegmentsIntegral(t *testing.T) {
+	start := time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC)
+	segments := []touSegment{
+		{start: start, end: start.Add(time.Hour), startValue: 0, endValue: 2},
+		{start: start.Add(time.Hour), end: start.Add(2 * time.Hour), startValue: 1, endValue: 1},
+	}
+	assert.InDelta(t, 1.5*1800+3600, segmentsIntegral(segments, start.Add(30*time.Minute), start.Add(2*time.Hour)), 1e-9)
+}
+
+func TestCollectCheapestBlockMetric(t *testing.T) {
+	tou := cheapestTestTOU
+	tou.CheapestBlock = &cheapestBlock{Duration: 3 * time.Hour}
+
+	testCh := make(chan prometheus.Metric)
+	go func() {
+		collectCheapestBlockMetric(testCh, metricNaming{}, tou, time.Date(2023, 12, 1, 12, 0, 0, 0, time.UTC))
+		close(testCh)
+	}()
+
+	m := <-testCh
+	actual := &dto.Metric{}
+	if err := m.Write(actual); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, float64(time.Date(2023, 12, 2, 1, 0, 0, 0, time.UTC).Unix()), actual.GetGauge().GetValue())
+}
+
+func TestValidateCheapestBlock(t *testing.T) {
+	assert.NoError(t, validateCheapestBlock(nil))
+	assert.NoError(t, validateCheapestBlock(&cheapestBlock{Duration: 3 * time.Hour}))
+	assert.Equal(t, errors.New(`Invalid cheapest block duration. Must be positive. Got: "0s"`), validateCheapestBlock(&cheapestBlock{}))
+	assert.Equal(t, errors.New(`Cheapest block within must be at least the duration. Got: "1h0m0s"`),
+		validateCheapestBlock(&cheapestBlock{Duration: 3 * time.Hour, Within: time.Hour}))
+	assert.Equal(t, errors.New(`Cheapest block within must be at least the duration. Got: "24h0m0s"`),
+		validateCheapestBlock(&cheapestBlock{Duration: 25 * time.Hour}))
+	assert.Equal(t, errors.New(`Invalid cheapest block within. Must be at most 168h0m0s. Got: "192h0m0s"`),
+		validateCheapestBlock(&cheapestBlock{Duration: 3 * time.Hour, Within: 8 * 24 * time.Hour}))
+}
+
+func TestRunningIntegral(t *testing.T) {
+	tou := cheapestTestTOU
+	tou.TimeWindows = append(tou.TimeWindows, timeWindow{Value: 0.4, startHour: 7, endHour: 9, RampIn: time.Hour})
+	from := time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC)
+	segments := touSegments(tou, from, from.Add(48*time.Hour))
+	integrals := newRunningIntegral(segments)
+
+	for _, block := range [][2]time.Duration{{0, time.Hour}, {90 * time.Minute, 7*time.Hour + 30*time.Minute}, {6 * time.Hour, 30 * time.Hour}, {0, 48 * time.Hour}} {
+		start, end := from.Add(block[0]), from.Add(block[1])
+		assert.InDelta(t, segmentsIntegral(segments, start, end), integrals.until(end)-integrals.until(start), 1e-9, block)
+	}
+}
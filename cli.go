@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// runCommand runs a subcommand, and returns the exit code.
+func runCommand(args []string, stdout io.Writer) int {
+	switch args[0] {
+	case "import-urdb":
+		return runImportURDB(args[1:], stdout)
+	case "plan":
+		return runPlan(args[1:], stdout)
+	}
+	fmt.Fprintf(os.Stderr, "Unknown command \"%s\"\n\nCommands:\n  import-urdb  Convert an OpenEI URDB tariff into time of use config\n  plan         Plan the cheapest slots to run a flexible load in\n", args[0])
+	return 2
+}
+
+// runImportURDB converts a URDB tariff file into time of use config, and
+// writes it as YAML.
+func runImportURDB(args []string, stdout io.Writer) int {
+	fs := flag.NewFlagSet("import-urdb", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: time_of_use_exporter import-urdb [flags] <urdb.json>")
+		fs.PrintDefaults()
+	}
+	name := fs.String("name", "electricity_price", "Metric name")
+	description := fs.String("description", "", "Metric help. Defaults to the tariff name")
+	timezone := fs.String("timezone", "", "Timezone of the tariff")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	t, err := loadURDBFile(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error loading URDB tariff:", err)
+		return 1
+	}
+	windows, err := urdbTimeWindows(t)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error converting URDB tariff:", err)
+		return 1
+	}
+
+	tou := timeOfUse{
+		Name:        *name,
+		Description: *description,
+		Timezone:    *timezone,
+		TimeWindows: windows,
+	}
+	if tou.Description == "" {
+		tou.Description = t.Utility + " " + t.Name
+	}
+
+	b, err := yaml.Marshal(config{TimeOfUse: []timeOfUse{tou}})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error writing config:", err)
+		return 1
+	}
+	stdout.Write(b)
+	return 0
+}
+
+// runPlan plans the cheapest slots to run a flexible load in against a time
+// of use from the config, and writes the plan as JSON.
+func runPlan(args []string, stdout io.Writer) int {
+	fs := flag.NewFlagSet("plan", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: time_of_use_exporter plan [flags]")
+		fs.PrintDefaults()
+	}
+	configFile := fs.String("config", "./config.yaml", "Config file. Defaults to CONFIG_FILE if set")
+	name := fs.String("name", "", "Name of the time of use")
+	var req planRequest
+	fs.DurationVar(&req.Runtime, "runtime", 0, "Total time to run for")
+	fs.DurationVar(&req.Slot, "slot", defaultPlanSlot, "Slot granularity")
+	fs.DurationVar(&req.MinRun, "min-run", 0, "Shortest run of contiguous slots. Defaults to one slot")
+	fs.Float64Var(&req.Power, "power", 1, "Power drawn while running")
+	notBefore := fs.String("not-before", "", "RFC 3339 time to start from. Defaults to now")
+	deadline := fs.String("deadline", "", "RFC 3339 time to finish by. Defaults to 24h after not-before")
+	if os.Getenv("CONFIG_FILE") != "" {
+		*configFile = os.Getenv("CONFIG_FILE")
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *name == "" || req.Runtime == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	var err error
+	req.NotBefore = time.Now()
+	if *notBefore != "" {
+		if req.NotBefore, err = time.Parse(time.RFC3339, *notBefore); err != nil {
+			fmt.Fprintln(os.Stderr, "Invalid not-before:", err)
+			return 2
+		}
+	}
+	req.Deadline = req.NotBefore.Add(defaultCheapestWithin)
+	if *deadline != "" {
+		if req.Deadline, err = time.Parse(time.RFC3339, *deadline); err != nil {
+			fmt.Fprintln(os.Stderr, "Invalid deadline:", err)
+			return 2
+		}
+	}
+
+	c, err := loadConfig(*configFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error loading config:", err)
+		return 1
+	}
+	setLiveConfig(c)
+	tou, loc, err := findTimeOfUse(*name)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	switch tou.Source {
+	case "file":
+		reloadFeedFile(tou)
+	case "http":
+		f := newHTTPFeedFetcher(tou.Name, *tou.Feed)
+		f.loadCache()
+		if err := f.fetch(context.Background()); err != nil {
+			fmt.Fprintln(os.Stderr, "Error fetching feed, using the cache if any:", err)
+		}
+	}
+
+	req.NotBefore = req.NotBefore.In(loc)
+	p, err := planLoad(tou, req)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error planning:", err)
+		return 1
+	}
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(p); err != nil {
+		fmt.Fprintln(os.Stderr, "Error writing plan:", err)
+		return 1
+	}
+	return 0
+}
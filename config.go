@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
@@ -14,32 +16,268 @@ import (
 )
 
 type config struct {
-	LocalizedTimezones []string    `yaml:"localized_timezones"`
-	TimeOfUse          []timeOfUse `yaml:"time_of_use,omitempty"`
+	LocalizedTimezones []localizedTimezone `yaml:"localized_timezones"`
+	TimeOfUse          []timeOfUse         `yaml:"time_of_use,omitempty"`
+	FixedCharges       []fixedCharge       `yaml:"fixed_charges,omitempty"`
+	BillingCycles      []billingCycle      `yaml:"billing_cycles,omitempty"`
+	FiscalCalendars    []fiscalCalendar    `yaml:"fiscal_calendars,omitempty"`
+	Locations          []location          `yaml:"locations,omitempty"`
+	MetricNaming       metricNaming        `yaml:"metric_naming,omitempty"`
+}
+
+// metricNaming customises the names and labels of the exporter's metrics.
+type metricNaming struct {
+	// Prefix of the exporter's own metric names. Defaults to tou_exporter
+	Namespace string `yaml:"namespace,omitempty"`
+	// Also prefix time of use and fixed charge metric names with the namespace
+	PrefixTimeOfUse bool `yaml:"prefix_time_of_use,omitempty"`
+	// Name of the timezone label. Defaults to tz
+	TimezoneLabel string `yaml:"timezone_label,omitempty"`
+	// Values of the timezone label by timezone, instead of the timezone name
+	TimezoneAliases map[string]string `yaml:"timezone_aliases,omitempty"`
+	// Drop the day and month name labels of localized metrics
+	DropDayLabel   bool `yaml:"drop_day_label,omitempty"`
+	DropMonthLabel bool `yaml:"drop_month_label,omitempty"`
+}
+
+// localizedTimezone is a timezone to expose localized calendar metrics in.
+// Configured as either just the timezone name, or a mapping.
+type localizedTimezone struct {
+	Timezone string `yaml:"timezone"`
+	// Metric families to expose, replacing the defaults of minute, hour,
+	// day_of_week, day_of_month, month and the UTC offset and zone transition
+	// families
+	Metrics []string `yaml:"metrics,omitempty"`
+	// Locale of the day and month name labels. Defaults to en
+	Locale string `yaml:"locale,omitempty"`
+}
+
+func (l *localizedTimezone) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if err := unmarshal(&l.Timezone); err == nil {
+		return nil
+	}
+	type plain localizedTimezone
+	return unmarshal((*plain)(l))
+}
+
+func (l localizedTimezone) MarshalYAML() (interface{}, error) {
+	if len(l.Metrics) == 0 && l.Locale == "" {
+		return l.Timezone, nil
+	}
+	type plain localizedTimezone
+	return plain(l), nil
+}
+
+type billingCycle struct {
+	Name          string `yaml:"name"`
+	Timezone      string `yaml:"timezone,omitempty"`
+	billingPeriod `yaml:",inline"`
+}
+
+// location is a place on earth to calculate solar events for.
+type location struct {
+	Name      string  `yaml:"name"`
+	Latitude  float64 `yaml:"latitude"`
+	Longitude float64 `yaml:"longitude"`
+	// Timezone days start at midnight in. If unset, UTC is used
+	Timezone string `yaml:"timezone,omitempty"`
+}
+
+// fiscalCalendar is a fiscal year either starting on the same date every
+// year, or made of whole weeks split into periods by a pattern such as 4-4-5.
+type fiscalCalendar struct {
+	Name     string `yaml:"name"`
+	Timezone string `yaml:"timezone,omitempty"`
+	// Date years start on, for date based calendars. Defaults to 1 January
+	StartMonth int `yaml:"start_month,omitempty"`
+	StartDay   int `yaml:"start_day,omitempty"`
+	// Weeks in each period of a quarter, for week based calendars. One of
+	// 4-4-5, 4-5-4 or 5-4-4
+	Pattern string `yaml:"pattern,omitempty"`
+	// Week based years end on the end_weekday, from 0-6 where 0 is Sunday,
+	// which is either the last in end_month, or nearest the end of end_month
+	EndMonth   int    `yaml:"end_month,omitempty"`
+	EndWeekday int    `yaml:"end_weekday,omitempty"`
+	EndRule    string `yaml:"end_rule,omitempty"`
+	// Whether years are numbered by the calendar year they start or end in.
+	// Week based years end in the year of their end_month, even when their
+	// last day is early in the next year. Defaults to end
+	YearLabel string `yaml:"year_label,omitempty"`
 }
 
 type timeOfUse struct {
-	Name         string            `yaml:"name"`
-	Description  string            `yaml:"description"`
-	Timezone     string            `yaml:"timezone,omitempty"`
-	Labels       map[string]string `yaml:"labels,omitempty"`
-	DefaultValue float64           `yaml:"default_value"`
-	TimeWindows  []timeWindow      `yaml:"time_windows"`
+	Name           string            `yaml:"name"`
+	Description    string            `yaml:"description"`
+	Timezone       string            `yaml:"timezone,omitempty"`
+	Labels         map[string]string `yaml:"labels,omitempty"`
+	VariableLabels []string          `yaml:"variable_labels,omitempty"`
+	DefaultValue   float64           `yaml:"default_value"`
+	TimeWindows    []timeWindow      `yaml:"time_windows"`
+	// Expose a <name>_window_active state set, with one series per window name
+	WindowActiveMetric bool `yaml:"window_active_metric,omitempty"`
+	// Don't emit the value series when no time window matches, rather than
+	// falling back to DefaultValue
+	AbsentWhenUnmatched bool `yaml:"absent_when_unmatched,omitempty"`
+	// Expose <name>_integral_total and <name>_window_seconds_total counters
+	IntegralMetrics bool `yaml:"integral_metrics,omitempty"`
+	// Report the time weighted average value of the current settlement period
+	SettlementPeriod time.Duration `yaml:"settlement_period,omitempty"`
+	// Ranges to report the min, max and time weighted mean of the schedule
+	// over, and the percentile of the current value. Any of "day" or "next_24h"
+	Statistics []string `yaml:"statistics,omitempty"`
+	// Expose the start of the cheapest block of time, as found by the
+	// cheapest API
+	CheapestBlock *cheapestBlock `yaml:"cheapest_block,omitempty"`
+	// Weekly grid of values, converted into time windows on load
+	Profile *profile `yaml:"profile,omitempty"`
+	// Tariff file to convert into time windows on load
+	Import *tariffImport `yaml:"import,omitempty"`
+	// Name of a configured location, for time windows relative to solar
+	// events such as sunrise+30m
+	Location string `yaml:"location,omitempty"`
+	// Windows in which peak demand is measured for demand charges, with the
+	// demand rate as the value
+	DemandWindows []timeWindow `yaml:"demand_windows,omitempty"`
+	// Billing cycle demand is measured over. Calendar months if unset
+	DemandBillingCycle string `yaml:"demand_billing_cycle,omitempty"`
+	demandCycle        *billingCycle
+	// Source of time indexed values which take precedence over time windows.
+	// Either unset, "file" or "http"
+	Source string `yaml:"source,omitempty"`
+	Feed   *feed  `yaml:"feed,omitempty"`
 }
 
-type timeWindow struct {
-	Value       float64           `yaml:"value"`
-	Start       string            `yaml:"start"`
-	End         string            `yaml:"end"`
+// cheapestBlock is a contiguous block of time to find the cheapest start of.
+type cheapestBlock struct {
+	Duration time.Duration `yaml:"duration"`
+	// How far ahead to search. Defaults to 24h
+	Within time.Duration `yaml:"within,omitempty"`
+}
+
+type feed struct {
+	// File source
+	Path string `yaml:"path,omitempty"`
+	// csv or json, inferred from the path extension if unset
+	Format string `yaml:"format,omitempty"`
+
+	// HTTP source
+	URL          string        `yaml:"url,omitempty"`
+	PollInterval time.Duration `yaml:"poll_interval,omitempty"`
+	Timeout      time.Duration `yaml:"timeout,omitempty"`
+	// Where to keep the last good feed, loaded on start up
+	CachePath string `yaml:"cache_path,omitempty"`
+	// JSONPath style paths to the list of rows in the response, and to the
+	// fields within each row
+	RowsPath   string `yaml:"rows_path,omitempty"`
+	StartField string `yaml:"start_field,omitempty"`
+	EndField   string `yaml:"end_field,omitempty"`
+	ValueField string `yaml:"value_field,omitempty"`
+}
+
+// fixedCharge is a charge which doesn't depend on the time of day, such as a
+// daily supply charge, prorated over its period.
+type fixedCharge struct {
+	Name        string            `yaml:"name"`
+	Description string            `yaml:"description"`
+	Timezone    string            `yaml:"timezone,omitempty"`
 	Labels      map[string]string `yaml:"labels,omitempty"`
-	Days        []int             `yaml:"days,omitempty"`
+	Amount      float64           `yaml:"amount"`
+	// day, month, or billing_period
+	Period        string         `yaml:"period"`
+	BillingPeriod *billingPeriod `yaml:"billing_period,omitempty"`
+	// Name of a billing cycle to use as the billing period
+	BillingCycle string `yaml:"billing_cycle,omitempty"`
+	cycle        *billingCycle
+}
+
+// billingPeriod defines recurring billing periods, either starting on a day of
+// each month, or of a fixed number of days from an anchor date.
+type billingPeriod struct {
+	// Day of the month periods start on, from 1-31. In shorter months periods
+	// start on the last day of the month.
+	StartDay int `yaml:"start_day,omitempty"`
+	// Date of the start of any period, in YYYY-MM-DD format
+	Anchor     string `yaml:"anchor,omitempty"`
+	LengthDays int    `yaml:"length_days,omitempty"`
+}
+
+type tariffImport struct {
+	// Only urdb, the OpenEI Utility Rate Database JSON format, is supported
+	Format string `yaml:"format"`
+	Path   string `yaml:"path"`
+}
+
+type profile struct {
+	SlotSize  time.Duration `yaml:"slot_size"`
+	LabelName string        `yaml:"label_name,omitempty"`
+	// One row per day of the week, starting with Sunday
+	Days []profileDay `yaml:"days"`
+}
+
+type profileDay struct {
+	Values []float64 `yaml:"values"`
+	Labels []string  `yaml:"labels,omitempty"`
+}
+
+type timeWindow struct {
+	Name   string            `yaml:"name,omitempty"`
+	Value  float64           `yaml:"value"`
+	Start  string            `yaml:"start"`
+	End    string            `yaml:"end"`
+	Labels map[string]string `yaml:"labels,omitempty"`
+	Days   []int             `yaml:"days,omitempty"`
+	// Months of the year the window is valid for, from 1-12
+	Months []int `yaml:"months,omitempty"`
+	// Ramp linearly from the previous value at the start of the window, and to
+	// the following value at the end of the window
+	RampIn  time.Duration `yaml:"ramp_in,omitempty"`
+	RampOut time.Duration `yaml:"ramp_out,omitempty"`
+	// Piecewise linear values within the window, replaces Value
+	Points []rampPoint `yaml:"points,omitempty"`
+	// Consumption tiers, each exported as a series. Value is set from the
+	// first tier
+	Tiers []tier `yaml:"tiers,omitempty"`
+
 	startHour   int
 	startMinute int
 	endHour     int
 	endMinute   int
+	// Set when the start or end is relative to a solar event
+	startSolar *solarAnchor
+	endSolar   *solarAnchor
+}
+
+type tier struct {
+	// Upper bound of the tier. Unset for the last tier means unbounded
+	UpTo  *float64 `yaml:"up_to,omitempty"`
+	Value float64  `yaml:"value"`
+}
+
+type rampPoint struct {
+	Time   string  `yaml:"time"`
+	Value  float64 `yaml:"value"`
+	hour   int
+	minute int
 }
 
-var liveConfig = config{}
+var (
+	liveConfig   = config{}
+	liveConfigMu sync.RWMutex
+)
+
+// getLiveConfig returns the current config. The config is replaced as a whole
+// on reload, so the returned config is never modified.
+func getLiveConfig() config {
+	liveConfigMu.RLock()
+	defer liveConfigMu.RUnlock()
+	return liveConfig
+}
+
+func setLiveConfig(c config) {
+	liveConfigMu.Lock()
+	defer liveConfigMu.Unlock()
+	liveConfig = c
+}
 
 func configInit() {
 	f := "./config.yaml"
@@ -51,7 +289,8 @@ func configInit() {
 		slog.Error("Error loading config", "err", err)
 		os.Exit(1)
 	}
-	liveConfig = c
+	setLiveConfig(c)
+	syncFeeds(c)
 	go configWatcher(f)
 }
 
@@ -70,14 +309,24 @@ func configWatcher(filepath string) {
 				if !ok {
 					return
 				}
-				if event.Has(fsnotify.Write) {
-					c, err := loadConfig(filepath)
-					if err != nil {
-						slog.Error("Error loading config", "err", err)
-						continue
+				if !samePath(event.Name, filepath) {
+					// Feeds replaced atomically are created by a rename
+					if event.Has(fsnotify.Write) || event.Has(fsnotify.Create) {
+						reloadFeedFiles(event.Name)
 					}
-					liveConfig = c
+					continue
+				}
+				if !event.Has(fsnotify.Write) {
+					continue
 				}
+				c, err := loadConfig(filepath)
+				if err != nil {
+					slog.Error("Error loading config", "err", err)
+					continue
+				}
+				setLiveConfig(c)
+				syncFeeds(c)
+				watchFeedFiles(watcher, c)
 			case err, ok := <-watcher.Errors:
 				slog.Debug("Watcher error", "err", err, "ok", ok)
 				if !ok {
@@ -93,6 +342,7 @@ func configWatcher(filepath string) {
 		slog.Error("Error adding filepath to watcher", "err", err)
 	}
 	slog.Debug("Added config watcher", "filepath", filepath)
+	watchFeedFiles(watcher, getLiveConfig())
 
 	// Block main goroutine
 	<-make(chan struct{})
@@ -111,39 +361,657 @@ func loadConfig(filepath string) (config, error) {
 		return config{}, err
 	}
 
+	err = validateMetricNaming(c.MetricNaming)
+	if err != nil {
+		slog.Error("Error validating metric naming", "err", err)
+		return config{}, err
+	}
+
 	for _, loc := range c.LocalizedTimezones {
-		_, err := time.LoadLocation(loc)
+		_, err := loadLocation(loc.Timezone)
+		if err != nil {
+			return config{}, err
+		}
+		err = validateLocalizedMetrics(loc.Metrics)
+		if err != nil {
+			slog.Error("Error validating localized metrics", "err", err, "timezone", loc.Timezone)
+			return config{}, err
+		}
+		err = validateLocale(loc.Locale)
 		if err != nil {
+			slog.Error("Error validating locale", "err", err, "timezone", loc.Timezone)
 			return config{}, err
 		}
 	}
 
 	for i, tou := range c.TimeOfUse {
-		_, err := time.LoadLocation(tou.Timezone)
+		_, err := loadLocation(tou.Timezone)
 		if err != nil {
 			slog.Error("Error parsing timezone", "err", err, "time_of_use", tou.Name, "timezone", tou.Timezone)
 			return config{}, err
 		}
 
+		if tou.Profile != nil {
+			windows, err := profileTimeWindows(*tou.Profile)
+			if err != nil {
+				slog.Error("Error converting profile", "err", err, "time_of_use", tou.Name)
+				return config{}, err
+			}
+			c.TimeOfUse[i].TimeWindows = append(c.TimeOfUse[i].TimeWindows, windows...)
+			tou = c.TimeOfUse[i]
+		}
+
+		if tou.Import != nil {
+			windows, err := importTimeWindows(*tou.Import)
+			if err != nil {
+				slog.Error("Error importing tariff", "err", err, "time_of_use", tou.Name, "path", tou.Import.Path)
+				return config{}, err
+			}
+			c.TimeOfUse[i].TimeWindows = append(c.TimeOfUse[i].TimeWindows, windows...)
+			tou = c.TimeOfUse[i]
+		}
+
+		err = parseDemandWindows(&c, i)
+		if err != nil {
+			slog.Error("Error parsing demand windows", "err", err, "time_of_use", tou.Name)
+			return config{}, err
+		}
+
+		err = validateSource(tou)
+		if err != nil {
+			slog.Error("Error validating source", "err", err, "time_of_use", tou.Name)
+			return config{}, err
+		}
+
+		err = validateVariableLabels(tou)
+		if err != nil {
+			slog.Error("Error validating variable labels", "err", err, "time_of_use", tou.Name)
+			return config{}, err
+		}
+		err = validateTierLabels(tou)
+		if err != nil {
+			slog.Error("Error validating tier labels", "err", err, "time_of_use", tou.Name)
+			return config{}, err
+		}
+		err = validateTOUTimezoneLabel(tou, c.MetricNaming.timezoneLabel())
+		if err != nil {
+			slog.Error("Error validating labels", "err", err, "time_of_use", tou.Name)
+			return config{}, err
+		}
+
+		err = validateSettlementPeriod(tou.SettlementPeriod)
+		if err != nil {
+			slog.Error("Error validating settlement period", "err", err, "time_of_use", tou.Name)
+			return config{}, err
+		}
+
+		err = validateStatistics(tou.Statistics)
+		if err != nil {
+			slog.Error("Error validating statistics", "err", err, "time_of_use", tou.Name)
+			return config{}, err
+		}
+
+		err = validateCheapestBlock(tou.CheapestBlock)
+		if err != nil {
+			slog.Error("Error validating cheapest block", "err", err, "time_of_use", tou.Name)
+			return config{}, err
+		}
+
+		err = validateWindowNames(tou)
+		if err != nil {
+			slog.Error("Error validating time window names", "err", err, "time_of_use", tou.Name)
+			return config{}, err
+		}
+
+		solarLoc, err := findLocation(c, tou.Location)
+		if err != nil {
+			slog.Error("Error finding location", "err", err, "time_of_use", tou.Name)
+			return config{}, err
+		}
+
 		for j, tw := range tou.TimeWindows {
 			slog.Debug("Parsing time window", "time_of_use", tou.Name, "time_window", tw)
-			c.TimeOfUse[i].TimeWindows[j].startHour, c.TimeOfUse[i].TimeWindows[j].startMinute, err = parseWindowTimes(tw.Start)
+			c.TimeOfUse[i].TimeWindows[j].startHour, c.TimeOfUse[i].TimeWindows[j].startMinute, c.TimeOfUse[i].TimeWindows[j].startSolar, err = parseWindowTime(tw.Start, solarLoc)
 			if err != nil {
 				slog.Error("Error parsing time window start", "err", err, "time_of_use", tou.Name, "time_window", tw)
 				return config{}, err
 			}
 
-			c.TimeOfUse[i].TimeWindows[j].endHour, c.TimeOfUse[i].TimeWindows[j].endMinute, err = parseWindowTimes(tw.End)
+			c.TimeOfUse[i].TimeWindows[j].endHour, c.TimeOfUse[i].TimeWindows[j].endMinute, c.TimeOfUse[i].TimeWindows[j].endSolar, err = parseWindowTime(tw.End, solarLoc)
 			if err != nil {
 				slog.Error("Error parsing time window end", "err", err, "time_of_use", tou.Name, "time_window", tw)
 				return config{}, err
 			}
+
+			for _, m := range tw.Months {
+				if m < 1 || m > 12 {
+					err = fmt.Errorf("Invalid month. Must be 1-12. Got: %d", m)
+					slog.Error("Error parsing time window months", "err", err, "time_of_use", tou.Name, "time_window", tw)
+					return config{}, err
+				}
+			}
+
+			for k, p := range tw.Points {
+				c.TimeOfUse[i].TimeWindows[j].Points[k].hour, c.TimeOfUse[i].TimeWindows[j].Points[k].minute, err = parseWindowTimes(p.Time)
+				if err != nil {
+					slog.Error("Error parsing time window point", "err", err, "time_of_use", tou.Name, "time_window", tw)
+					return config{}, err
+				}
+			}
+
+			err = validateRamps(c.TimeOfUse[i].TimeWindows[j])
+			if err != nil {
+				slog.Error("Error validating time window ramps", "err", err, "time_of_use", tou.Name, "time_window", tw)
+				return config{}, err
+			}
+
+			err = validateTiers(tw, tou.SettlementPeriod)
+			if err != nil {
+				slog.Error("Error validating time window tiers", "err", err, "time_of_use", tou.Name, "time_window", tw)
+				return config{}, err
+			}
+			if len(tw.Tiers) > 0 {
+				c.TimeOfUse[i].TimeWindows[j].Value = tw.Tiers[0].Value
+			}
+		}
+	}
+
+	for i, bc := range c.BillingCycles {
+		_, err := loadLocation(bc.Timezone)
+		if err != nil {
+			slog.Error("Error parsing timezone", "err", err, "billing_cycle", bc.Name, "timezone", bc.Timezone)
+			return config{}, err
+		}
+
+		err = validateBillingCycle(bc, c.BillingCycles[:i])
+		if err != nil {
+			slog.Error("Error validating billing cycle", "err", err, "billing_cycle", bc.Name)
+			return config{}, err
+		}
+	}
+
+	for i, fc := range c.FiscalCalendars {
+		_, err := loadLocation(fc.Timezone)
+		if err != nil {
+			slog.Error("Error parsing timezone", "err", err, "fiscal_calendar", fc.Name, "timezone", fc.Timezone)
+			return config{}, err
+		}
+
+		err = validateFiscalCalendar(fc, c.FiscalCalendars[:i])
+		if err != nil {
+			slog.Error("Error validating fiscal calendar", "err", err, "fiscal_calendar", fc.Name)
+			return config{}, err
+		}
+	}
+
+	for i, l := range c.Locations {
+		_, err := loadLocation(l.Timezone)
+		if err != nil {
+			slog.Error("Error parsing timezone", "err", err, "location", l.Name, "timezone", l.Timezone)
+			return config{}, err
+		}
+
+		err = validateLocation(l, c.Locations[:i])
+		if err != nil {
+			slog.Error("Error validating location", "err", err, "location", l.Name)
+			return config{}, err
+		}
+	}
+
+	for i, fc := range c.FixedCharges {
+		if fc.BillingCycle != "" {
+			idx := slices.IndexFunc(c.BillingCycles, func(bc billingCycle) bool { return bc.Name == fc.BillingCycle })
+			if idx < 0 {
+				err := fmt.Errorf(`Unknown billing cycle "%s"`, fc.BillingCycle)
+				slog.Error("Error validating fixed charge", "err", err, "fixed_charge", fc.Name)
+				return config{}, err
+			}
+			c.FixedCharges[i].cycle = &c.BillingCycles[idx]
+			fc = c.FixedCharges[i]
+		}
+
+		_, err := loadLocation(fc.Timezone)
+		if err != nil {
+			slog.Error("Error parsing timezone", "err", err, "fixed_charge", fc.Name, "timezone", fc.Timezone)
+			return config{}, err
+		}
+
+		err = validateFixedCharge(fc, c.FixedCharges[:i])
+		if err != nil {
+			slog.Error("Error validating fixed charge", "err", err, "fixed_charge", fc.Name)
+			return config{}, err
+		}
+		if _, ok := fc.Labels[c.MetricNaming.timezoneLabel()]; ok {
+			tzLabel := c.MetricNaming.timezoneLabel()
+			err = fmt.Errorf(`"%s" is reserved and can not be used as a label`, tzLabel)
+			slog.Error("Error validating fixed charge", "err", err, "fixed_charge", fc.Name)
+			return config{}, err
 		}
 	}
 
 	return c, nil
 }
 
+// validateMetricNaming ensures the namespace and timezone label are valid
+// Prometheus names.
+func validateMetricNaming(n metricNaming) error {
+	if n.Namespace != "" && !metricNameRegexp.MatchString(n.Namespace) {
+		return fmt.Errorf(`Invalid metric namespace. Must match %s. Got: "%s"`, metricNameRegexp, n.Namespace)
+	}
+	if n.TimezoneLabel != "" && !labelNameRegexp.MatchString(n.TimezoneLabel) {
+		return fmt.Errorf(`Invalid timezone label. Must match %s. Got: "%s"`, labelNameRegexp, n.TimezoneLabel)
+	}
+	if slices.Contains(reservedLabels(), n.TimezoneLabel) {
+		return fmt.Errorf(`Timezone label "%s" clashes with a label of the exporter's metrics`, n.TimezoneLabel)
+	}
+	return nil
+}
+
+// validateLocalizedMetrics ensures localized metric families exist.
+func validateLocalizedMetrics(families []string) error {
+	for _, f := range families {
+		if !slices.ContainsFunc(localizedFamilies, func(lf localizedFamily) bool { return lf.name == f }) {
+			return fmt.Errorf(`Unknown localized metric "%s"`, f)
+		}
+	}
+	return nil
+}
+
+// validateLocale ensures day and month names exist for a locale.
+func validateLocale(locale string) error {
+	if _, ok := locales[locale]; locale != "" && !ok {
+		return fmt.Errorf(`Unknown locale "%s". Must be one of %s`, locale, strings.Join(localeCodes(), ", "))
+	}
+	return nil
+}
+
+// parseDemandWindows parses the demand window times of a time of use, and
+// resolves its demand billing cycle.
+func parseDemandWindows(c *config, i int) error {
+	tou := &c.TimeOfUse[i]
+	solarLoc, err := findLocation(*c, tou.Location)
+	if err != nil {
+		return err
+	}
+	for j, tw := range tou.DemandWindows {
+		// Only the demand rate is reported, so fields which vary the value or
+		// labels of a time window are not supported
+		if tw.RampIn != 0 || tw.RampOut != 0 || len(tw.Points) > 0 || len(tw.Tiers) > 0 || len(tw.Labels) > 0 {
+			return fmt.Errorf("Demand window %s-%s can only have a name, value, start, end, days and months", tw.Start, tw.End)
+		}
+		tou.DemandWindows[j].startHour, tou.DemandWindows[j].startMinute, tou.DemandWindows[j].startSolar, err = parseWindowTime(tw.Start, solarLoc)
+		if err != nil {
+			return err
+		}
+		tou.DemandWindows[j].endHour, tou.DemandWindows[j].endMinute, tou.DemandWindows[j].endSolar, err = parseWindowTime(tw.End, solarLoc)
+		if err != nil {
+			return err
+		}
+		for _, m := range tw.Months {
+			if m < 1 || m > 12 {
+				return fmt.Errorf("Invalid month. Must be 1-12. Got: %d", m)
+			}
+		}
+	}
+
+	if tou.DemandBillingCycle != "" {
+		idx := slices.IndexFunc(c.BillingCycles, func(bc billingCycle) bool { return bc.Name == tou.DemandBillingCycle })
+		if idx < 0 {
+			return fmt.Errorf(`Unknown billing cycle "%s"`, tou.DemandBillingCycle)
+		}
+		tou.demandCycle = &c.BillingCycles[idx]
+	}
+	return nil
+}
+
+func validateSource(tou timeOfUse) error {
+	switch tou.Source {
+	case "":
+		return nil
+	case "file":
+		if tou.Feed == nil || tou.Feed.Path == "" {
+			return errors.New("A feed path is required for a file source")
+		}
+		if f := feedFormat(*tou.Feed); f != "csv" && f != "json" {
+			return fmt.Errorf(`Unknown feed format "%s". Must be csv or json`, f)
+		}
+		return nil
+	case "http":
+		if tou.Feed == nil || tou.Feed.URL == "" {
+			return errors.New("A feed url is required for an http source")
+		}
+		if tou.Feed.PollInterval < 0 || tou.Feed.Timeout < 0 {
+			return errors.New("Feed poll_interval and timeout can not be negative")
+		}
+		return nil
+	}
+	return fmt.Errorf(`Unknown source "%s". Must be file or http`, tou.Source)
+}
+
+// validateTOUTimezoneLabel ensures a time of use, its variable labels and its
+// time windows don't set the timezone label, which would clash with it.
+func validateTOUTimezoneLabel(tou timeOfUse, tzLabel string) error {
+	if slices.Contains(tou.VariableLabels, tzLabel) {
+		return fmt.Errorf(`"%s" is reserved and can not be used as a variable label`, tzLabel)
+	}
+	_, ok := tou.Labels[tzLabel]
+	if ok || slices.ContainsFunc(tou.TimeWindows, func(tw timeWindow) bool { _, ok := tw.Labels[tzLabel]; return ok }) {
+		return fmt.Errorf(`"%s" is reserved and can not be used as a label`, tzLabel)
+	}
+	return nil
+}
+
+// validateVariableLabels ensures that when a time of use declares its variable
+// labels, time windows only set labels from that declared schema. Otherwise
+// the stable metric desc would not be able to represent the window labels.
+func validateVariableLabels(tou timeOfUse) error {
+	if len(tou.VariableLabels) == 0 {
+		return nil
+	}
+	for i, l := range tou.VariableLabels {
+		if slices.Contains(tou.VariableLabels[:i], l) {
+			return fmt.Errorf(`Duplicate variable label: "%s"`, l)
+		}
+	}
+	for _, tw := range tou.TimeWindows {
+		for k := range tw.Labels {
+			if !slices.Contains(tou.VariableLabels, k) {
+				return fmt.Errorf(`Time window label "%s" is not declared in variable_labels`, k)
+			}
+		}
+	}
+	return nil
+}
+
+// validateWindowNames ensures every time window is named when a metric keyed
+// by window name is enabled. Multiple windows may share a name, for example
+// morning and evening peaks.
+func validateWindowNames(tou timeOfUse) error {
+	if !tou.WindowActiveMetric && !tou.IntegralMetrics {
+		return nil
+	}
+	// The window name is a variable label of the window metrics
+	if _, ok := tou.Labels["window"]; ok || slices.Contains(tou.VariableLabels, "window") {
+		return errors.New(`"window" is reserved and can not be used as a label when window_active_metric or integral_metrics is enabled`)
+	}
+	for _, tw := range tou.TimeWindows {
+		if tw.Name == "" {
+			return fmt.Errorf(`Time window %s-%s must have a name when window_active_metric or integral_metrics is enabled`, tw.Start, tw.End)
+		}
+		if tw.Name == defaultWindowName || tw.Name == feedWindowName && tou.Source != "" {
+			return fmt.Errorf(`Time window name "%s" is reserved`, tw.Name)
+		}
+	}
+	return nil
+}
+
+// validateSettlementPeriod ensures settlement periods line up with the start of
+// every day, so periods can be aligned to midnight.
+func validateSettlementPeriod(p time.Duration) error {
+	if p == 0 {
+		return nil
+	}
+	if p < 0 || p%time.Minute != 0 || (24*time.Hour)%p != 0 {
+		return fmt.Errorf(`Invalid settlement period. Must be a whole number of minutes that evenly divides 24h. Got: "%s"`, p)
+	}
+	return nil
+}
+
+// validateStatistics ensures statistics are only requested for known ranges.
+func validateStatistics(ranges []string) error {
+	for _, r := range ranges {
+		if !slices.Contains(statisticsRanges, r) {
+			return fmt.Errorf(`Unknown statistics range "%s". Must be day or next_24h`, r)
+		}
+	}
+	return nil
+}
+
+// validateCheapestBlock ensures a cheapest block fits within its search range.
+func validateCheapestBlock(b *cheapestBlock) error {
+	if b == nil {
+		return nil
+	}
+	if b.Duration <= 0 {
+		return fmt.Errorf(`Invalid cheapest block duration. Must be positive. Got: "%s"`, b.Duration)
+	}
+	if b.Within > maxCheapestWithin {
+		return fmt.Errorf(`Invalid cheapest block within. Must be at most %s. Got: "%s"`, maxCheapestWithin, b.Within)
+	}
+	within := b.Within
+	if within == 0 {
+		within = defaultCheapestWithin
+	}
+	if within < b.Duration {
+		return fmt.Errorf(`Cheapest block within must be at least the duration. Got: "%s"`, within)
+	}
+	return nil
+}
+
+// validateRamps ensures ramps fit within their time window, and points are in
+// order within the time window. Expects window and point times to be parsed.
+func validateRamps(tw timeWindow) error {
+	if tw.RampIn < 0 || tw.RampOut < 0 {
+		return errors.New("Ramp durations can not be negative")
+	}
+	if len(tw.Points) > 0 && (tw.RampIn > 0 || tw.RampOut > 0) {
+		return errors.New("Time windows can not have both points and ramps")
+	}
+
+	if tw.startSolar != nil || tw.endSolar != nil {
+		if len(tw.Points) > 0 {
+			return errors.New("Time windows relative to solar events can not have points")
+		}
+		// Ramps can only be checked against the window on each day
+		return nil
+	}
+
+	start := tw.startHour*60 + tw.startMinute
+	end := tw.endHour*60 + tw.endMinute
+	if end == 0 {
+		end = 24 * 60
+	}
+	if tw.RampIn+tw.RampOut > time.Duration(end-start)*time.Minute {
+		return fmt.Errorf("Ramps of %s and %s are longer than the time window %s-%s", tw.RampIn, tw.RampOut, tw.Start, tw.End)
+	}
+
+	prev := start
+	for _, p := range tw.Points {
+		t := p.hour*60 + p.minute
+		if t < prev || t > end {
+			return fmt.Errorf(`Point "%s" must be in order and within the time window %s-%s`, p.Time, tw.Start, tw.End)
+		}
+		prev = t
+	}
+	return nil
+}
+
+// validateTierLabels ensures the labels of a time of use with tiers don't clash
+// with the tier labels added to each series.
+func validateTierLabels(tou timeOfUse) error {
+	if !touHasTiers(tou) {
+		return nil
+	}
+	for _, l := range touTierLabels {
+		reserved := slices.Contains(tou.VariableLabels, l) || slices.ContainsFunc(tou.TimeWindows, func(tw timeWindow) bool {
+			_, ok := tw.Labels[l]
+			return ok
+		})
+		if _, ok := tou.Labels[l]; ok || reserved {
+			return fmt.Errorf(`"%s" is reserved and can not be used as a label when time windows have tiers`, l)
+		}
+	}
+	return nil
+}
+
+// validateTiers ensures tier upper bounds are increasing, and only the last
+// tier is unbounded. Tier values are reported as configured, so can't be
+// averaged by a settlement period, ramped or interpolated.
+func validateTiers(tw timeWindow, settlementPeriod time.Duration) error {
+	tiers := tw.Tiers
+	if len(tiers) > 0 && settlementPeriod > 0 {
+		return errors.New("Time windows with tiers can not be used with a settlement_period")
+	}
+	if len(tiers) > 0 && (tw.RampIn > 0 || tw.RampOut > 0 || len(tw.Points) > 0) {
+		return errors.New("Time windows with tiers can not have ramps or points")
+	}
+	for i, t := range tiers {
+		if t.UpTo == nil {
+			if i != len(tiers)-1 {
+				return fmt.Errorf("Only the last tier can be unbounded. Tier %d has no up_to", i)
+			}
+			continue
+		}
+		if i > 0 && *t.UpTo <= *tiers[i-1].UpTo {
+			return fmt.Errorf("Tier up_to must be increasing. Tier %d up_to %g is not greater than %g", i, *t.UpTo, *tiers[i-1].UpTo)
+		}
+	}
+	return nil
+}
+
+func validateFixedCharge(fc fixedCharge, previous []fixedCharge) error {
+	if fc.Name == "" {
+		return errors.New("Fixed charges must have a name")
+	}
+	if slices.ContainsFunc(previous, func(p fixedCharge) bool { return p.Name == fc.Name }) {
+		return fmt.Errorf(`Duplicate fixed charge name "%s"`, fc.Name)
+	}
+	switch fc.Period {
+	case "day", "month":
+		return nil
+	case "billing_period":
+		if fc.cycle != nil {
+			return nil
+		}
+		if fc.BillingPeriod == nil {
+			return errors.New("A billing_period or billing_cycle is required for a billing_period fixed charge")
+		}
+		return validateBillingPeriod(*fc.BillingPeriod)
+	}
+	return fmt.Errorf(`Unknown fixed charge period "%s". Must be day, month, or billing_period`, fc.Period)
+}
+
+func validateBillingCycle(bc billingCycle, previous []billingCycle) error {
+	if bc.Name == "" {
+		return errors.New("Billing cycles must have a name")
+	}
+	if slices.ContainsFunc(previous, func(p billingCycle) bool { return p.Name == bc.Name }) {
+		return fmt.Errorf(`Duplicate billing cycle name "%s"`, bc.Name)
+	}
+	return validateBillingPeriod(bc.billingPeriod)
+}
+
+func validateBillingPeriod(b billingPeriod) error {
+	if b.StartDay != 0 && b.Anchor != "" {
+		return errors.New("Billing periods can have either start_day, or anchor and length_days, not both")
+	}
+	if b.Anchor != "" {
+		if _, err := time.Parse(time.DateOnly, b.Anchor); err != nil {
+			return fmt.Errorf(`Invalid billing period anchor. Must be YYYY-MM-DD. Got: "%s"`, b.Anchor)
+		}
+		if b.LengthDays < 1 {
+			return fmt.Errorf("Billing period length_days must be at least 1. Got: %d", b.LengthDays)
+		}
+		return nil
+	}
+	if b.StartDay < 1 || b.StartDay > 31 {
+		return fmt.Errorf("Billing period start_day must be 1-31. Got: %d", b.StartDay)
+	}
+	return nil
+}
+
+// validateFiscalCalendar ensures a fiscal calendar is either date or week
+// based, with a valid start or end.
+func validateFiscalCalendar(fc fiscalCalendar, previous []fiscalCalendar) error {
+	if fc.Name == "" {
+		return errors.New("Fiscal calendars must have a name")
+	}
+	if slices.ContainsFunc(previous, func(p fiscalCalendar) bool { return p.Name == fc.Name }) {
+		return fmt.Errorf(`Duplicate fiscal calendar name "%s"`, fc.Name)
+	}
+	switch fc.YearLabel {
+	case "", "start", "end":
+	default:
+		return fmt.Errorf(`Unknown fiscal calendar year_label "%s". Must be start or end`, fc.YearLabel)
+	}
+
+	if fc.Pattern == "" {
+		if fc.EndMonth != 0 || fc.EndWeekday != 0 || fc.EndRule != "" {
+			return errors.New("Fiscal calendars without a pattern can't have end_month, end_weekday or end_rule")
+		}
+		month := max(fc.StartMonth, 1)
+		if fc.StartMonth < 0 || fc.StartMonth > 12 {
+			return fmt.Errorf("Fiscal calendar start_month must be 1-12. Got: %d", fc.StartMonth)
+		}
+		// 29 February only exists in leap years
+		if fc.StartDay < 0 || fc.StartDay > daysInMonth(2023, time.Month(month)) {
+			return fmt.Errorf("Fiscal calendar start_day must be a day of start_month. Got: %d", fc.StartDay)
+		}
+		return nil
+	}
+
+	if _, ok := fiscalPatterns[fc.Pattern]; !ok {
+		return fmt.Errorf(`Unknown fiscal calendar pattern "%s". Must be 4-4-5, 4-5-4 or 5-4-4`, fc.Pattern)
+	}
+	if fc.StartMonth != 0 || fc.StartDay != 0 {
+		return errors.New("Fiscal calendars with a pattern can't have start_month or start_day")
+	}
+	if fc.EndMonth < 1 || fc.EndMonth > 12 {
+		return fmt.Errorf("Fiscal calendar end_month must be 1-12. Got: %d", fc.EndMonth)
+	}
+	if fc.EndWeekday < 0 || fc.EndWeekday > 6 {
+		return fmt.Errorf("Fiscal calendar end_weekday must be 0-6. Got: %d", fc.EndWeekday)
+	}
+	switch fc.EndRule {
+	case "", "last", "nearest":
+	default:
+		return fmt.Errorf(`Unknown fiscal calendar end_rule "%s". Must be last or nearest`, fc.EndRule)
+	}
+	return nil
+}
+
+// validateLocation ensures a location has a unique name, and coordinates on
+// earth.
+func validateLocation(l location, previous []location) error {
+	if l.Name == "" {
+		return errors.New("Locations must have a name")
+	}
+	if slices.ContainsFunc(previous, func(p location) bool { return p.Name == l.Name }) {
+		return fmt.Errorf(`Duplicate location name "%s"`, l.Name)
+	}
+	if l.Latitude < -90 || l.Latitude > 90 {
+		return fmt.Errorf("Location latitude must be -90 to 90. Got: %g", l.Latitude)
+	}
+	if l.Longitude < -180 || l.Longitude > 180 {
+		return fmt.Errorf("Location longitude must be -180 to 180. Got: %g", l.Longitude)
+	}
+	return nil
+}
+
+// findLocation returns the configured location with the given name, or nil
+// if the name is empty.
+func findLocation(c config, name string) (*location, error) {
+	if name == "" {
+		return nil, nil
+	}
+	idx := slices.IndexFunc(c.Locations, func(l location) bool { return l.Name == name })
+	if idx < 0 {
+		return nil, fmt.Errorf(`Unknown location "%s"`, name)
+	}
+	return &c.Locations[idx], nil
+}
+
+// parseWindowTime parses a time window start or end, either in hh:mm format,
+// or relative to a solar event at the location.
+func parseWindowTime(t string, loc *location) (int, int, *solarAnchor, error) {
+	anchor, err := parseSolarAnchor(t, loc)
+	if err != nil || anchor != nil {
+		return 0, 0, anchor, err
+	}
+	h, m, err := parseWindowTimes(t)
+	return h, m, nil, err
+}
+
 func parseWindowTimes(t string) (int, int, error) {
 	// Split string by :
 	parts := strings.Split(t, ":")
@@ -11,9 +11,9 @@ import (
 	"gopkg.in/yaml.v2"
 )
 
-var testConfig = config{LocalizedTimezones: []string{
-	"Pacific/Auckland",
-	"Pacific/Chatham",
+var testConfig = config{LocalizedTimezones: []localizedTimezone{
+	{Timezone: "Pacific/Auckland"},
+	{Timezone: "Pacific/Chatham"},
 }}
 var testConfigYaml, _ = yaml.Marshal(testConfig)
 
@@ -23,8 +23,8 @@ func TestLoadConfig(t *testing.T) {
 		require.IsType(t, config{}, c)
 		require.EqualValues(
 			t, config{
-				LocalizedTimezones: []string{
-					"Pacific/Auckland",
+				LocalizedTimezones: []localizedTimezone{
+					{Timezone: "Pacific/Auckland"},
 				},
 				TimeOfUse: []timeOfUse{
 					{
@@ -66,7 +66,7 @@ func TestConfigWatcher(t *testing.T) {
 	go configWatcher(f.Name())
 	time.Sleep(10 * time.Millisecond) // Give the watcher time to start
 
-	assert.Equal(t, config{}, liveConfig)
+	assert.Equal(t, config{}, getLiveConfig())
 
 	err = os.WriteFile(f.Name(), testConfigYaml, 0644)
 	if err != nil {
@@ -74,7 +74,7 @@ func TestConfigWatcher(t *testing.T) {
 	}
 	time.Sleep(10 * time.Millisecond) // Give the watcher time to update
 
-	assert.Equal(t, testConfig, liveConfig)
+	assert.Equal(t, testConfig, getLiveConfig())
 }
 
 func TestConfigInit(t *testing.T) {
@@ -87,7 +87,7 @@ func TestConfigInit(t *testing.T) {
 	t.Setenv("CONFIG_FILE", f.Name())
 
 	configInit()
-	assert.Equal(t, testConfig, liveConfig)
+	assert.Equal(t, testConfig, getLiveConfig())
 }
 
 func TestParseWindowTimes(t *testing.T) {
@@ -136,3 +136,296 @@ func TestParseWindowTimes(t *testing.T) {
 		assert.Equal(t, tc.err, err, name)
 	}
 }
+
+func TestValidateVariableLabels(t *testing.T) {
+	testCases := map[string]struct {
+		input timeOfUse
+		err   error
+	}{
+		"no variable labels": {
+			input: timeOfUse{
+				TimeWindows: []timeWindow{{Labels: map[string]string{"rate": "Peak"}}},
+			},
+			err: nil,
+		},
+		"declared labels": {
+			input: timeOfUse{
+				VariableLabels: []string{"rate"},
+				TimeWindows:    []timeWindow{{Labels: map[string]string{"rate": "Peak"}}},
+			},
+			err: nil,
+		},
+		"undeclared label": {
+			input: timeOfUse{
+				VariableLabels: []string{"rate"},
+				TimeWindows:    []timeWindow{{Labels: map[string]string{"season": "Summer"}}},
+			},
+			err: errors.New(`Time window label "season" is not declared in variable_labels`),
+		},
+		"duplicate label": {
+			input: timeOfUse{VariableLabels: []string{"rate", "rate"}},
+			err:   errors.New(`Duplicate variable label: "rate"`),
+		},
+	}
+
+	for name, tc := range testCases {
+		assert.Equal(t, tc.err, validateVariableLabels(tc.input), name)
+	}
+}
+
+func TestValidateWindowNames(t *testing.T) {
+	testCases := map[string]struct {
+		input timeOfUse
+		err   error
+	}{
+		"disabled": {
+			input: timeOfUse{TimeWindows: []timeWindow{{Start: "07:00", End: "09:00"}}},
+			err:   nil,
+		},
+		"named": {
+			input: timeOfUse{
+				WindowActiveMetric: true,
+				TimeWindows:        []timeWindow{{Name: "peak"}, {Name: "peak"}},
+			},
+			err: nil,
+		},
+		"unnamed": {
+			input: timeOfUse{
+				WindowActiveMetric: true,
+				TimeWindows:        []timeWindow{{Start: "07:00", End: "09:00"}},
+			},
+			err: errors.New(`Time window 07:00-09:00 must have a name when window_active_metric or integral_metrics is enabled`),
+		},
+		"reserved name": {
+			input: timeOfUse{
+				WindowActiveMetric: true,
+				TimeWindows:        []timeWindow{{Name: "default"}},
+			},
+			err: errors.New(`Time window name "default" is reserved`),
+		},
+		"reserved window label": {
+			input: timeOfUse{
+				IntegralMetrics: true,
+				Labels:          map[string]string{"window": "x"},
+				TimeWindows:     []timeWindow{{Name: "peak"}},
+			},
+			err: errors.New(`"window" is reserved and can not be used as a label when window_active_metric or integral_metrics is enabled`),
+		},
+		"reserved window variable label": {
+			input: timeOfUse{
+				WindowActiveMetric: true,
+				VariableLabels:     []string{"window"},
+			},
+			err: errors.New(`"window" is reserved and can not be used as a label when window_active_metric or integral_metrics is enabled`),
+		},
+	}
+
+	for name, tc := range testCases {
+		assert.Equal(t, tc.err, validateWindowNames(tc.input), name)
+	}
+}
+
+func TestValidateSettlementPeriod(t *testing.T) {
+	assert.NoError(t, validateSettlementPeriod(0))
+	assert.NoError(t, validateSettlementPeriod(30*time.Minute))
+	assert.NoError(t, validateSettlementPeriod(24*time.Hour))
+	assert.Equal(t,
+		errors.New(`Invalid settlement period. Must be a whole number of minutes that evenly divides 24h. Got: "7m0s"`),
+		validateSettlementPeriod(7*time.Minute),
+	)
+	assert.Error(t, validateSettlementPeriod(90*time.Second))
+	assert.Error(t, validateSettlementPeriod(-30*time.Minute))
+}
+
+func TestValidateRamps(t *testing.T) {
+	testCases := map[string]struct {
+		input timeWindow
+		err   error
+	}{
+		"ramps fit": {
+			input: timeWindow{Start: "07:00", End: "09:00", startHour: 7, endHour: 9, RampIn: time.Hour, RampOut: time.Hour},
+			err:   nil,
+		},
+		"ramps to midnight": {
+			input: timeWindow{Start: "22:00", End: "00:00", startHour: 22, RampOut: 2 * time.Hour},
+			err:   nil,
+		},
+		"ramps too long": {
+			input: timeWindow{Start: "07:00", End: "09:00", startHour: 7, endHour: 9, RampIn: time.Hour, RampOut: 90 * time.Minute},
+			err:   errors.New("Ramps of 1h0m0s and 1h30m0s are longer than the time window 07:00-09:00"),
+		},
+		"points and ramps": {
+			input: timeWindow{RampIn: time.Hour, Points: []rampPoint{{Time: "07:00"}}},
+			err:   errors.New("Time windows can not have both points and ramps"),
+		},
+		"points out of order": {
+			input: timeWindow{
+				Start: "07:00", End: "09:00", startHour: 7, endHour: 9,
+				Points: []rampPoint{{Time: "08:00", hour: 8}, {Time: "07:30", hour: 7, minute: 30}},
+			},
+			err: errors.New(`Point "07:30" must be in order and within the time window 07:00-09:00`),
+		},
+		"point outside window": {
+			input: timeWindow{
+				Start: "07:00", End: "09:00", startHour: 7, endHour: 9,
+				Points: []rampPoint{{Time: "10:00", hour: 10}},
+			},
+			err: errors.New(`Point "10:00" must be in order and within the time window 07:00-09:00`),
+		},
+	}
+
+	for name, tc := range testCases {
+		assert.Equal(t, tc.err, validateRamps(tc.input), name)
+	}
+}
+
+func TestLoadConfigProfile(t *testing.T) {
+	f, err := os.CreateTemp("", "config_test.*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	os.WriteFile(f.Name(), []byte(`
+time_of_use:
+- name: profile_test
+  profile:
+    slot_size: 12h
+    days:
+    - values: [1, 2]
+    - values: [1, 2]
+    - values: [1, 2]
+    - values: [1, 2]
+    - values: [1, 2]
+    - values: [1, 2]
+    - values: [1, 3]
+`), 0644)
+
+	c, err := loadConfig(f.Name())
+	if assert.NoError(t, err) {
+		tou := c.TimeOfUse[0]
+		assert.Len(t, tou.TimeWindows, 3)
+		assert.Equal(t, 2.0, calculateTOUValue(tou, time.Date(2023, 12, 1, 13, 0, 0, 0, time.UTC)), "Friday afternoon")
+		assert.Equal(t, 3.0, calculateTOUValue(tou, time.Date(2023, 12, 2, 13, 0, 0, 0, time.UTC)), "Saturday afternoon")
+		assert.Equal(t, 1.0, calculateTOUValue(tou, time.Date(2023, 12, 2, 1, 0, 0, 0, time.UTC)), "Saturday morning")
+	}
+}
+
+func TestValidateSource(t *testing.T) {
+	assert.NoError(t, validateSource(timeOfUse{}))
+	assert.NoError(t, validateSource(timeOfUse{Source: "file", Feed: &feed{Path: "prices.csv"}}))
+	assert.NoError(t, validateSource(timeOfUse{Source: "file", Feed: &feed{Path: "prices", Format: "json"}}))
+	assert.Equal(t, errors.New("A feed path is required for a file source"), validateSource(timeOfUse{Source: "file"}))
+	assert.Equal(t, errors.New(`Unknown feed format "txt". Must be csv or json`),
+		validateSource(timeOfUse{Source: "file", Feed: &feed{Path: "prices.txt"}}))
+	assert.NoError(t, validateSource(timeOfUse{Source: "http", Feed: &feed{URL: "http://localhost/prices"}}))
+	assert.Equal(t, errors.New("A feed url is required for an http source"), validateSource(timeOfUse{Source: "http", Feed: &feed{}}))
+	assert.Equal(t, errors.New(`Unknown source "ftp". Must be file or http`), validateSource(timeOfUse{Source: "ftp"}))
+}
+
+func TestValidateTiers(t *testing.T) {
+	eight, twelve := 8.0, 12.0
+	assert.NoError(t, validateTiers(timeWindow{}, 0))
+	assert.NoError(t, validateTiers(timeWindow{RampIn: time.Hour}, 30*time.Minute))
+	assert.NoError(t, validateTiers(timeWindow{Tiers: []tier{{UpTo: &eight}, {UpTo: &twelve}, {}}}, 0))
+	assert.Equal(t, errors.New("Only the last tier can be unbounded. Tier 0 has no up_to"), validateTiers(timeWindow{Tiers: []tier{{}, {UpTo: &eight}}}, 0))
+	assert.Equal(t, errors.New("Tier up_to must be increasing. Tier 1 up_to 8 is not greater than 12"),
+		validateTiers(timeWindow{Tiers: []tier{{UpTo: &twelve}, {UpTo: &eight}}}, 0))
+	assert.Equal(t, errors.New("Time windows with tiers can not be used with a settlement_period"),
+		validateTiers(timeWindow{Tiers: []tier{{}}}, 30*time.Minute))
+	assert.Equal(t, errors.New("Time windows with tiers can not have ramps or points"),
+		validateTiers(timeWindow{Tiers: []tier{{}}, RampOut: time.Hour}, 0))
+	assert.Equal(t, errors.New("Time windows with tiers can not have ramps or points"),
+		validateTiers(timeWindow{Tiers: []tier{{}}, Points: []rampPoint{{Time: "07:00"}}}, 0))
+}
+
+func TestValidateTierLabels(t *testing.T) {
+	tiered := []timeWindow{{Tiers: []tier{{Value: 0.1}}}}
+	assert.NoError(t, validateTierLabels(timeOfUse{Labels: map[string]string{"tier": "1"}}))
+	assert.NoError(t, validateTierLabels(timeOfUse{Labels: map[string]string{"rate": "Day"}, TimeWindows: tiered}))
+	assert.Equal(t, errors.New(`"tier" is reserved and can not be used as a label when time windows have tiers`),
+		validateTierLabels(timeOfUse{Labels: map[string]string{"tier": "1"}, TimeWindows: tiered}))
+	assert.Equal(t, errors.New(`"tier_upper_bound" is reserved and can not be used as a label when time windows have tiers`),
+		validateTierLabels(timeOfUse{VariableLabels: []string{"tier_upper_bound"}, TimeWindows: tiered}))
+	assert.Equal(t, errors.New(`"tier" is reserved and can not be used as a label when time windows have tiers`),
+		validateTierLabels(timeOfUse{TimeWindows: append([]timeWindow{{Labels: map[string]string{"tier": "1"}}}, tiered...)}))
+}
+
+func TestLoadConfigBillingCycles(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "config_test.*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(f.Name(), []byte(`
+billing_cycles:
+- name: power
+  timezone: Pacific/Auckland
+  start_day: 15
+fixed_charges:
+- name: account_fee
+  amount: 10
+  period: billing_period
+  billing_cycle: power
+`), 0644)
+
+	c, err := loadConfig(f.Name())
+	if assert.NoError(t, err) {
+		assert.Equal(t, []billingCycle{{Name: "power", Timezone: "Pacific/Auckland", billingPeriod: billingPeriod{StartDay: 15}}}, c.BillingCycles)
+		assert.Equal(t, &c.BillingCycles[0], c.FixedCharges[0].cycle, "should use the billing cycle's period")
+	}
+
+	os.WriteFile(f.Name(), []byte(`
+fixed_charges:
+- name: account_fee
+  period: billing_period
+  billing_cycle: water
+`), 0644)
+	_, err = loadConfig(f.Name())
+	assert.Equal(t, errors.New(`Unknown billing cycle "water"`), err)
+}
+
+func TestLoadConfigLocalizedTimezones(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "config_test.*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(f.Name(), []byte(`
+localized_timezones:
+- Pacific/Auckland
+- timezone: Pacific/Chatham
+  metrics: [hour, iso_week]
+- timezone: Europe/Berlin
+  locale: de
+`), 0644)
+
+	c, err := loadConfig(f.Name())
+	if assert.NoError(t, err) {
+		assert.Equal(t, []localizedTimezone{
+			{Timezone: "Pacific/Auckland"},
+			{Timezone: "Pacific/Chatham", Metrics: []string{"hour", "iso_week"}},
+			{Timezone: "Europe/Berlin", Locale: "de"},
+		}, c.LocalizedTimezones)
+	}
+
+	os.WriteFile(f.Name(), []byte(`
+localized_timezones:
+- timezone: Pacific/Chatham
+  metrics: [fortnight]
+`), 0644)
+	_, err = loadConfig(f.Name())
+	assert.Equal(t, errors.New(`Unknown localized metric "fortnight"`), err)
+
+	os.WriteFile(f.Name(), []byte(`
+localized_timezones:
+- timezone: Europe/Berlin
+  locale: de-AT
+`), 0644)
+	_, err = loadConfig(f.Name())
+	assert.Equal(t, errors.New(`Unknown locale "de-AT". Must be one of de, en, es, fr, ja, mi, zh`), err)
+}
+
+func TestMarshalLocalizedTimezone(t *testing.T) {
+	out, err := yaml.Marshal([]localizedTimezone{{Timezone: "Pacific/Auckland"}, {Timezone: "Europe/Berlin", Locale: "de"}})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "- Pacific/Auckland\n- timezone: Europe/Berlin\n  locale: de\n", string(out))
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// activeDemandWindow returns the first demand window matching the given time.
+func activeDemandWindow(tou timeOfUse, now time.Time) (timeWindow, bool) {
+	for _, tw := range tou.DemandWindows {
+		if isWithinTimeWindow(tw, now) {
+			return tw, true
+		}
+	}
+	return timeWindow{}, false
+}
+
+// demandPeriodBounds returns the demand billing period containing now, which
+// is the calendar month unless a demand billing cycle is configured. Billing
+// cycles start at midnight in their own timezone.
+func demandPeriodBounds(tou timeOfUse, now time.Time) (time.Time, time.Time) {
+	if tou.demandCycle != nil {
+		return tou.demandCycle.bounds(now)
+	}
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	return start, start.AddDate(0, 1, 0)
+}
+
+type demandDescs struct {
+	active      *prometheus.Desc
+	rate        *prometheus.Desc
+	periodStart *prometheus.Desc
+	periodEnd   *prometheus.Desc
+}
+
+func describeDemandMetrics(n metricNaming, tou timeOfUse) demandDescs {
+	labels := n.touConstLabels(tou)
+	return demandDescs{
+		active: prometheus.NewDesc(n.touMetricName(tou)+"_demand_measurement_active",
+			"Whether peak demand is currently measured for the demand charge of "+tou.Name, nil, labels),
+		rate: prometheus.NewDesc(n.touMetricName(tou)+"_demand_rate",
+			"Demand charge rate of the active demand window of "+tou.Name+", or 0 outside of demand windows", nil, labels),
+		periodStart: prometheus.NewDesc(n.touMetricName(tou)+"_demand_period_start_timestamp_seconds",
+			"Unix timestamp of the start of the current demand billing period of "+tou.Name, nil, labels),
+		periodEnd: prometheus.NewDesc(n.touMetricName(tou)+"_demand_period_end_timestamp_seconds",
+			"Unix timestamp of the end of the current demand billing period of "+tou.Name, nil, labels),
+	}
+}
+
+func collectDemandMetrics(ch chan<- prometheus.Metric, n metricNaming, tou timeOfUse, now time.Time) {
+	descs := describeDemandMetrics(n, tou)
+
+	active, rate := 0.0, 0.0
+	if tw, ok := activeDemandWindow(tou, now); ok {
+		active, rate = 1, tw.Value
+	}
+	start, end := demandPeriodBounds(tou, now)
+
+	ch <- prometheus.MustNewConstMetric(descs.active, prometheus.GaugeValue, active)
+	ch <- prometheus.MustNewConstMetric(descs.rate, prometheus.GaugeValue, rate)
+	ch <- prometheus.MustNewConstMetric(descs.periodStart, prometheus.GaugeValue, float64(start.Unix()))
+	ch <- prometheus.MustNewConstMetric(descs.periodEnd, prometheus.GaugeValue, float64(end.Unix()))
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDemandPeriodBounds(t *testing.T) {
+	auckland, err := time.LoadLocation("Pacific/Auckland")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start, end := demandPeriodBounds(timeOfUse{}, time.Date(2024, 2, 10, 12, 0, 0, 0, auckland))
+	assert.Equal(t, time.Date(2024, 2, 1, 0, 0, 0, 0, auckland), start)
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, auckland), end)
+
+	tou := timeOfUse{demandCycle: &billingCycle{Timezone: "Pacific/Auckland", billingPeriod: billingPeriod{StartDay: 15}}}
+	start, end = demandPeriodBounds(tou, time.Date(2024, 2, 10, 12, 0, 0, 0, auckland))
+	assert.Equal(t, time.Date(2024, 1, 15, 0, 0, 0, 0, auckland), start)
+	assert.Equal(t, time.Date(2024, 2, 15, 0, 0, 0, 0, auckland), end)
+
+	// The billing cycle's timezone, not the time of use's, sets when periods
+	// start. 2024-02-14 14:00 UTC is already 15 February in Sydney
+	tou = timeOfUse{demandCycle: &billingCycle{Timezone: "Australia/Sydney", billingPeriod: billingPeriod{StartDay: 15}}}
+	start, end = demandPeriodBounds(tou, time.Date(2024, 2, 14, 14, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2024, 2, 14, 13, 0, 0, 0, time.UTC), start.UTC())
+	assert.Equal(t, time.Date(2024, 3, 14, 13, 0, 0, 0, time.UTC), end.UTC())
+}
+
+func TestLoadConfigDemandWindows(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "config_test.*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(f.Name(), []byte(`
+billing_cycles:
+- name: power
+  start_day: 15
+time_of_use:
+- name: demand_test
+  demand_billing_cycle: power
+  demand_windows:
+  - value: 12.5
+    start: '16:00'
+    end: '21:00'
+    months: [6, 7, 8]
+`), 0644)
+
+	c, err := loadConfig(f.Name())
+	if assert.NoError(t, err) {
+		tou := c.TimeOfUse[0]
+		assert.Equal(t, &c.BillingCycles[0], tou.demandCycle)
+		_, ok := activeDemandWindow(tou, time.Date(2024, 7, 1, 17, 0, 0, 0, time.UTC))
+		assert.True(t, ok, "summer evening")
+		_, ok = activeDemandWindow(tou, time.Date(2024, 1, 1, 17, 0, 0, 0, time.UTC))
+		assert.False(t, ok, "winter evening")
+	}
+
+	os.WriteFile(f.Name(), []byte(`
+time_of_use:
+- name: demand_test
+  demand_billing_cycle: water
+`), 0644)
+	_, err = loadConfig(f.Name())
+	assert.Equal(t, errors.New(`Unknown billing cycle "water"`), err)
+
+	os.WriteFile(f.Name(), []byte(`
+time_of_use:
+- name: demand_test
+  demand_windows:
+  - value: 12.5
+    start: '16:00'
+    end: '21:00'
+    ramp_in: 30m
+`), 0644)
+	_, err = loadConfig(f.Name())
+	assert.Equal(t, errors.New(`Demand window 16:00-21:00 can only have a name, value, start, end, days and months`), err)
+}
+
+func TestCollectDemandMetrics(t *testing.T) {
+	tou := timeOfUse{
+		Name:     "demand_test",
+		Timezone: "UTC",
+		DemandWindows: []timeWindow{
+			{Value: 12.5, startHour: 16, endHour: 21},
+		},
+	}
+
+	tests := []struct {
+		now    time.Time
+		active float64
+		rate   float64
+	}{
+		{time.Date(2024, 7, 1, 17, 0, 0, 0, time.UTC), 1, 12.5},
+		{time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC), 0, 0},
+	}
+
+	for _, tc := range tests {
+		testCh := make(chan prometheus.Metric)
+		go func() {
+			collectDemandMetrics(testCh, metricNaming{}, tou, tc.now)
+			close(testCh)
+		}()
+
+		values := map[string]float64{}
+		for m := range testCh {
+			actual := &dto.Metric{}
+			if err := m.Write(actual); err != nil {
+				t.Fatal(err)
+			}
+			name := strings.Split(m.Desc().String(), `"`)[1]
+			values[name] = actual.GetGauge().GetValue()
+		}
+
+		assert.Equal(t, tc.active, values["demand_test_demand_measurement_active"], tc.now)
+		assert.Equal(t, tc.rate, values["demand_test_demand_rate"], tc.now)
+		assert.Equal(t, float64(time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC).Unix()), values["demand_test_demand_period_start_timestamp_seconds"])
+		assert.Equal(t, float64(time.Date(2024, 8, 1, 0, 0, 0, 0, time.UTC).Unix()), values["demand_test_demand_period_end_timestamp_seconds"])
+	}
+}
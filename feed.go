@@ -0,0 +1,274 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Name of the window state used while a feed row is active
+const feedWindowName = "feed"
+
+// feedRow is a value for a time of use over a fixed period of time, from a
+// price feed.
+type feedRow struct {
+	Start  time.Time         `json:"start"`
+	End    time.Time         `json:"end"`
+	Value  float64           `json:"value"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+type feedData struct {
+	rows    []feedRow
+	updated time.Time
+}
+
+var (
+	// Feed rows by time of use name
+	feeds   = map[string]feedData{}
+	feedsMu sync.RWMutex
+)
+
+// setFeed replaces the rows of a time of use's feed. Rows must be validated
+// with validateFeedRows first.
+func setFeed(name string, rows []feedRow, updated time.Time) {
+	feedsMu.Lock()
+	defer feedsMu.Unlock()
+	feeds[name] = feedData{rows: rows, updated: updated}
+}
+
+func getFeed(name string) (feedData, bool) {
+	feedsMu.RLock()
+	defer feedsMu.RUnlock()
+	f, ok := feeds[name]
+	return f, ok
+}
+
+// feedRowAt returns the feed row of a time of use covering now, if any.
+func feedRowAt(name string, now time.Time) (feedRow, bool) {
+	f, ok := getFeed(name)
+	if !ok {
+		return feedRow{}, false
+	}
+	// Rows are sorted and don't overlap, so only the last row starting at or
+	// before now can cover it
+	i := sort.Search(len(f.rows), func(i int) bool { return f.rows[i].Start.After(now) })
+	if i > 0 && now.Before(f.rows[i-1].End) {
+		return f.rows[i-1], true
+	}
+	return feedRow{}, false
+}
+
+// syncFeeds loads the feeds of every time of use using a file source, starts
+// pollers for HTTP sources, and drops feeds for time of use which no longer
+// have a source.
+func syncFeeds(c config) {
+	syncFeedPollers(c)
+
+	configured := map[string]bool{}
+	for _, tou := range c.TimeOfUse {
+		if tou.Source == "" {
+			continue
+		}
+		configured[tou.Name] = true
+		if tou.Source == "file" {
+			reloadFeedFile(tou)
+		}
+	}
+
+	feedsMu.Lock()
+	defer feedsMu.Unlock()
+	for name := range feeds {
+		if !configured[name] {
+			delete(feeds, name)
+		}
+	}
+}
+
+// reloadFeedFile loads a feed file, keeping the previous rows if it fails.
+func reloadFeedFile(tou timeOfUse) {
+	slog.Info("Loading feed", "time_of_use", tou.Name, "path", tou.Feed.Path)
+	rows, err := loadFeedFile(*tou.Feed, feedLabels(tou))
+	if err != nil {
+		slog.Error("Error loading feed", "err", err, "time_of_use", tou.Name, "path", tou.Feed.Path)
+		return
+	}
+	setFeed(tou.Name, rows, time.Now())
+}
+
+// reloadFeedFiles reloads every file feed with the given path.
+func reloadFeedFiles(path string) {
+	for _, tou := range getLiveConfig().TimeOfUse {
+		if tou.Source == "file" && samePath(tou.Feed.Path, path) {
+			reloadFeedFile(tou)
+		}
+	}
+}
+
+// watchFeedFiles adds the directories of all file feeds to the watcher, so
+// feeds which are replaced by renaming a new file over them are still seen.
+// Directories which are already watched are ignored by the watcher.
+func watchFeedFiles(watcher *fsnotify.Watcher, c config) {
+	for _, tou := range c.TimeOfUse {
+		if tou.Source != "file" {
+			continue
+		}
+		dir := filepath.Dir(tou.Feed.Path)
+		if err := watcher.Add(dir); err != nil {
+			slog.Error("Error adding feed to watcher", "err", err, "time_of_use", tou.Name, "path", dir)
+			continue
+		}
+		slog.Debug("Added feed watcher", "time_of_use", tou.Name, "path", dir)
+	}
+}
+
+// feedLabels returns the labels feed rows may set, which are the variable
+// labels, or the labels of a time of use without variable labels.
+func feedLabels(tou timeOfUse) []string {
+	if len(tou.VariableLabels) > 0 {
+		return tou.VariableLabels
+	}
+	return slices.Sorted(maps.Keys(tou.Labels))
+}
+
+// loadFeedFile loads and parses a feed file. Rows may only set the given
+// labels.
+func loadFeedFile(f feed, labels []string) ([]feedRow, error) {
+	b, err := os.ReadFile(f.Path)
+	if err != nil {
+		return nil, err
+	}
+	switch feedFormat(f) {
+	case "csv":
+		return parseFeedCSV(strings.NewReader(string(b)), labels)
+	case "json":
+		return parseFeedJSON(b, labels)
+	}
+	return nil, fmt.Errorf(`Unknown feed format "%s". Must be csv or json`, f.Format)
+}
+
+// feedFormat returns the configured feed format, or infers it from the file
+// extension.
+func feedFormat(f feed) string {
+	if f.Format != "" {
+		return f.Format
+	}
+	return strings.TrimPrefix(strings.ToLower(filepath.Ext(f.Path)), ".")
+}
+
+// parseFeedCSV parses a CSV feed with a header row. The start, end and value
+// columns are required, and any other columns set the given labels. Times
+// must be RFC 3339.
+func parseFeedCSV(r io.Reader, labels []string) ([]feedRow, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("Feed CSV is empty, expected a header row")
+	}
+
+	header := records[0]
+	for _, col := range []string{"start", "end", "value"} {
+		if !slices.Contains(header, col) {
+			return nil, fmt.Errorf(`Feed CSV is missing the "%s" column`, col)
+		}
+	}
+	for _, col := range header {
+		if col != "start" && col != "end" && col != "value" && !slices.Contains(labels, col) {
+			return nil, fmt.Errorf(`Feed CSV column "%s" is not a declared label`, col)
+		}
+	}
+
+	rows := make([]feedRow, 0, len(records)-1)
+	for i, record := range records[1:] {
+		row := feedRow{}
+		for j, col := range header {
+			v := strings.TrimSpace(record[j])
+			switch col {
+			case "start":
+				row.Start, err = time.Parse(time.RFC3339, v)
+			case "end":
+				row.End, err = time.Parse(time.RFC3339, v)
+			case "value":
+				row.Value, err = strconv.ParseFloat(v, 64)
+			default:
+				if v != "" {
+					if row.Labels == nil {
+						row.Labels = map[string]string{}
+					}
+					row.Labels[col] = v
+				}
+			}
+			if err != nil {
+				return nil, fmt.Errorf(`Error parsing feed CSV row %d column "%s": %w`, i+2, col, err)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, validateFeedRows(rows)
+}
+
+// parseFeedJSON parses a JSON feed, which is a list of rows with start, end,
+// value and optional labels keys. Rows may only set the given labels.
+func parseFeedJSON(b []byte, labels []string) ([]feedRow, error) {
+	rows := []feedRow{}
+	if err := json.Unmarshal(b, &rows); err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		for k := range r.Labels {
+			if !slices.Contains(labels, k) {
+				return nil, fmt.Errorf(`Feed row label "%s" is not a declared label`, k)
+			}
+		}
+	}
+	return rows, validateFeedRows(rows)
+}
+
+// validateFeedRows sorts feed rows by start time, and ensures they don't
+// overlap.
+func validateFeedRows(rows []feedRow) error {
+	slices.SortFunc(rows, func(a, b feedRow) int { return a.Start.Compare(b.Start) })
+	for i, r := range rows {
+		if !r.End.After(r.Start) {
+			return fmt.Errorf("Feed row must end after it starts. Got: %s - %s", r.Start.Format(time.RFC3339), r.End.Format(time.RFC3339))
+		}
+		if i > 0 && r.Start.Before(rows[i-1].End) {
+			return fmt.Errorf("Feed rows must not overlap. Got: %s - %s", r.Start.Format(time.RFC3339), rows[i-1].End.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+func collectFeedMetrics(ch chan<- prometheus.Metric, n metricNaming, tou timeOfUse) {
+	f, ok := getFeed(tou.Name)
+	if !ok {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(feedLastUpdate.desc(n), prometheus.GaugeValue, float64(f.updated.Unix()), tou.Name, tou.Source)
+
+	if len(f.rows) > 0 {
+		end := f.rows[len(f.rows)-1].End
+		ch <- prometheus.MustNewConstMetric(feedCoverageEnd.desc(n), prometheus.GaugeValue, float64(end.Unix()), tou.Name, tou.Source)
+	}
+}
+
+func samePath(a, b string) bool {
+	return filepath.Clean(a) == filepath.Clean(b)
+}
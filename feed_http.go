@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultFeedPollInterval = 15 * time.Minute
+	defaultFeedTimeout      = 10 * time.Second
+	// First retry delay after a failed fetch, doubled on each failure up to
+	// the poll interval
+	feedRetryBackoff = time.Second
+)
+
+type feedPoller struct {
+	feed   feed
+	cancel context.CancelFunc
+}
+
+var (
+	// Running HTTP feed pollers by time of use name
+	feedPollers   = map[string]feedPoller{}
+	feedPollersMu sync.Mutex
+)
+
+// syncFeedPollers starts a poller for each time of use with an HTTP source,
+// restarting pollers whose feed config changed and stopping any which are no
+// longer configured.
+func syncFeedPollers(c config) {
+	feedPollersMu.Lock()
+	defer feedPollersMu.Unlock()
+
+	configured := map[string]bool{}
+	for _, tou := range c.TimeOfUse {
+		if tou.Source != "http" {
+			continue
+		}
+		configured[tou.Name] = true
+		if p, ok := feedPollers[tou.Name]; ok {
+			if p.feed == *tou.Feed {
+				continue
+			}
+			p.cancel()
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		feedPollers[tou.Name] = feedPoller{feed: *tou.Feed, cancel: cancel}
+		go newHTTPFeedFetcher(tou.Name, *tou.Feed).poll(ctx)
+	}
+
+	for name, p := range feedPollers {
+		if !configured[name] {
+			p.cancel()
+			delete(feedPollers, name)
+		}
+	}
+}
+
+// httpFeedFetcher fetches a feed over HTTP, remembering the validators of the
+// last response for conditional requests.
+type httpFeedFetcher struct {
+	name         string
+	feed         feed
+	client       *http.Client
+	etag         string
+	lastModified string
+}
+
+func newHTTPFeedFetcher(name string, f feed) *httpFeedFetcher {
+	timeout := f.Timeout
+	if timeout == 0 {
+		timeout = defaultFeedTimeout
+	}
+	return &httpFeedFetcher{
+		name:   name,
+		feed:   f,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// poll loads the cached feed, then fetches the feed every poll interval until
+// the context is cancelled. Failed fetches are retried with exponential
+// backoff, and the last good feed is kept.
+func (f *httpFeedFetcher) poll(ctx context.Context) {
+	f.loadCache()
+
+	interval := f.feed.PollInterval
+	if interval == 0 {
+		interval = defaultFeedPollInterval
+	}
+	backoff := feedRetryBackoff
+	for {
+		wait := interval
+		if err := f.fetch(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			slog.Error("Error fetching feed", "err", err, "time_of_use", f.name, "url", f.feed.URL, "retry_in", backoff)
+			wait = backoff
+			backoff = min(backoff*2, interval)
+		} else {
+			backoff = feedRetryBackoff
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// fetch requests the feed, and updates the feed rows and cache if it changed.
+func (f *httpFeedFetcher) fetch(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.feed.URL, nil)
+	if err != nil {
+		return err
+	}
+	if f.etag != "" {
+		req.Header.Set("If-None-Match", f.etag)
+	}
+	if f.lastModified != "" {
+		req.Header.Set("If-Modified-Since", f.lastModified)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		slog.Debug("Feed not modified", "time_of_use", f.name, "url", f.feed.URL)
+		if current, ok := getFeed(f.name); ok {
+			setFeed(f.name, current.rows, time.Now())
+		}
+		return nil
+	case http.StatusOK:
+	default:
+		return fmt.Errorf("Unexpected feed response status: %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	rows, err := parseFeedHTTP(body, f.feed)
+	if err != nil {
+		return err
+	}
+
+	setFeed(f.name, rows, time.Now())
+	f.etag = resp.Header.Get("ETag")
+	f.lastModified = resp.Header.Get("Last-Modified")
+	f.saveCache(rows)
+	slog.Debug("Fetched feed", "time_of_use", f.name, "url", f.feed.URL, "rows", len(rows))
+	return nil
+}
+
+// loadCache loads the last good feed from disk, if there is a cache.
+func (f *httpFeedFetcher) loadCache() {
+	if f.feed.CachePath == "" {
+		return
+	}
+	info, err := os.Stat(f.feed.CachePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		slog.Error("Error loading feed cache", "err", err, "time_of_use", f.name, "path", f.feed.CachePath)
+		return
+	}
+	rows, err := loadFeedFile(feed{Path: f.feed.CachePath, Format: "json"}, nil)
+	if err != nil {
+		slog.Error("Error loading feed cache", "err", err, "time_of_use", f.name, "path", f.feed.CachePath)
+		return
+	}
+	setFeed(f.name, rows, info.ModTime())
+}
+
+// saveCache writes the feed rows to disk in the JSON feed format.
+func (f *httpFeedFetcher) saveCache(rows []feedRow) {
+	if f.feed.CachePath == "" {
+		return
+	}
+	b, err := json.Marshal(rows)
+	if err == nil {
+		err = os.WriteFile(f.feed.CachePath, b, 0644)
+	}
+	if err != nil {
+		slog.Error("Error saving feed cache", "err", err, "time_of_use", f.name, "path", f.feed.CachePath)
+	}
+}
+
+// parseFeedHTTP parses a JSON response using the feed's field mapping.
+func parseFeedHTTP(body []byte, f feed) ([]feedRow, error) {
+	var doc any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, err
+	}
+
+	items, err := lookupJSONPath(doc, f.RowsPath)
+	if err != nil {
+		return nil, err
+	}
+	list, ok := items.([]any)
+	if !ok {
+		return nil, fmt.Errorf(`Feed rows path "%s" is not a list`, f.RowsPath)
+	}
+
+	rows := make([]feedRow, 0, len(list))
+	for i, item := range list {
+		row := feedRow{}
+		if row.Start, err = lookupFeedTime(item, f.StartField, "start"); err == nil {
+			if row.End, err = lookupFeedTime(item, f.EndField, "end"); err == nil {
+				row.Value, err = lookupFeedValue(item, f.ValueField, "value")
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing feed row %d: %w", i, err)
+		}
+		rows = append(rows, row)
+	}
+	return rows, validateFeedRows(rows)
+}
+
+// lookupJSONPath looks up a JSONPath style path, such as $.data.prices[0].start,
+// in a decoded JSON document. Only child and index selectors are supported.
+func lookupJSONPath(doc any, path string) (any, error) {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if path == "" {
+		return doc, nil
+	}
+	path = strings.ReplaceAll(strings.ReplaceAll(path, "[", "."), "]", "")
+
+	v := doc
+	for _, key := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]any:
+			child, ok := node[key]
+			if !ok {
+				return nil, fmt.Errorf(`Key "%s" not found in path "%s"`, key, path)
+			}
+			v = child
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, fmt.Errorf(`Invalid index "%s" in path "%s"`, key, path)
+			}
+			v = node[i]
+		default:
+			return nil, fmt.Errorf(`Can not look up "%s" in path "%s"`, key, path)
+		}
+	}
+	return v, nil
+}
+
+// lookupFeedTime looks up a time which is either an RFC 3339 string, or a
+// number of seconds since the unix epoch.
+func lookupFeedTime(item any, path, fallback string) (time.Time, error) {
+	if path == "" {
+		path = fallback
+	}
+	v, err := lookupJSONPath(item, path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	switch t := v.(type) {
+	case string:
+		return time.Parse(time.RFC3339, t)
+	case float64:
+		return time.Unix(0, int64(t*float64(time.Second))), nil
+	}
+	return time.Time{}, fmt.Errorf(`Invalid time at "%s": %v`, path, v)
+}
+
+// lookupFeedValue looks up a value which is either a number, or a string
+// containing a number.
+func lookupFeedValue(item any, path, fallback string) (float64, error) {
+	if path == "" {
+		path = fallback
+	}
+	v, err := lookupJSONPath(item, path)
+	if err != nil {
+		return 0, err
+	}
+	switch n := v.(type) {
+	case float64:
+		return n, nil
+	case string:
+		return strconv.ParseFloat(n, 64)
+	}
+	return 0, fmt.Errorf(`Invalid value at "%s": %v`, path, v)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testHTTPFeed = `{
+	"data": {
+		"prices": [
+			{"interval": {"from": "2023-12-01T00:00:00Z", "to": 1701391500}, "price": "0.12"},
+			{"interval": {"from": "2023-12-01T00:45:00Z", "to": "2023-12-01T01:00:00Z"}, "price": 0.15}
+		]
+	}
+}`
+
+var testHTTPFeedMapping = feed{
+	RowsPath:   "$.data.prices",
+	StartField: "interval.from",
+	EndField:   "interval.to",
+	ValueField: "price",
+}
+
+func TestParseFeedHTTP(t *testing.T) {
+	rows, err := parseFeedHTTP([]byte(testHTTPFeed), testHTTPFeedMapping)
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.True(t, time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC).Equal(rows[0].Start))
+	assert.True(t, time.Date(2023, 12, 1, 0, 45, 0, 0, time.UTC).Equal(rows[0].End), "should parse unix timestamps")
+	assert.Equal(t, 0.12, rows[0].Value, "should parse string values")
+	assert.Equal(t, 0.15, rows[1].Value)
+
+	_, err = parseFeedHTTP([]byte(testHTTPFeed), feed{RowsPath: "$.data"})
+	assert.EqualError(t, err, `Feed rows path "$.data" is not a list`)
+
+	_, err = parseFeedHTTP([]byte(testHTTPFeed), feed{RowsPath: "$.data.prices"})
+	assert.EqualError(t, err, `Error parsing feed row 0: Key "start" not found in path "start"`)
+}
+
+func TestLookupJSONPath(t *testing.T) {
+	doc := map[string]any{"a": []any{map[string]any{"b": 1.0}}}
+
+	v, err := lookupJSONPath(doc, "$.a[0].b")
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, v)
+
+	v, err = lookupJSONPath(doc, "$")
+	assert.NoError(t, err)
+	assert.Equal(t, doc, v)
+
+	_, err = lookupJSONPath(doc, "a[1]")
+	assert.EqualError(t, err, `Invalid index "1" in path "a.1"`)
+}
+
+func TestHTTPFeedFetcher(t *testing.T) {
+	requests := 0
+	fail := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(testHTTPFeed))
+	}))
+	defer server.Close()
+
+	f := testHTTPFeedMapping
+	f.URL = server.URL
+	f.CachePath = filepath.Join(t.TempDir(), "feed_cache.json")
+	fetcher := newHTTPFeedFetcher("http_feed_test", f)
+	defer syncFeeds(config{})
+
+	require.NoError(t, fetcher.fetch(context.Background()))
+	row, ok := feedRowAt("http_feed_test", time.Date(2023, 12, 1, 0, 50, 0, 0, time.UTC))
+	assert.True(t, ok)
+	assert.Equal(t, 0.15, row.Value)
+	assert.FileExists(t, f.CachePath, "should cache the last good feed")
+
+	require.NoError(t, fetcher.fetch(context.Background()), "not modified should succeed")
+	_, ok = feedRowAt("http_feed_test", time.Date(2023, 12, 1, 0, 50, 0, 0, time.UTC))
+	assert.True(t, ok, "not modified should keep the feed")
+
+	fail = true
+	assert.EqualError(t, fetcher.fetch(context.Background()), "Unexpected feed response status: 500 Internal Server Error")
+	_, ok = feedRowAt("http_feed_test", time.Date(2023, 12, 1, 0, 50, 0, 0, time.UTC))
+	assert.True(t, ok, "failed fetches should keep the last good feed")
+	assert.Equal(t, 3, requests)
+
+	// A new fetcher should start from the cache
+	setFeed("http_feed_test", nil, time.Time{})
+	newHTTPFeedFetcher("http_feed_test", f).loadCache()
+	row, ok = feedRowAt("http_feed_test", time.Date(2023, 12, 1, 0, 10, 0, 0, time.UTC))
+	assert.True(t, ok, "should load the cached feed")
+	assert.Equal(t, 0.12, row.Value)
+}
+
+func TestSyncFeedPollers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"start": "2023-12-01T00:00:00Z", "end": "2023-12-01T00:30:00Z", "value": 0.1}]`))
+	}))
+	defer server.Close()
+
+	tou := timeOfUse{Name: "http_poller_test", Source: "http", Feed: &feed{URL: server.URL}}
+	syncFeeds(config{TimeOfUse: []timeOfUse{tou}})
+	assert.Eventually(t, func() bool {
+		_, ok := feedRowAt(tou.Name, time.Date(2023, 12, 1, 0, 10, 0, 0, time.UTC))
+		return ok
+	}, time.Second, 10*time.Millisecond, "poller should fetch the feed")
+
+	syncFeeds(config{})
+	feedPollersMu.Lock()
+	assert.Empty(t, feedPollers, "pollers should be stopped")
+	feedPollersMu.Unlock()
+	_, ok := getFeed(tou.Name)
+	assert.False(t, ok, "feed should be dropped")
+}
+
+func TestHTTPFeedFetcherTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	fetcher := newHTTPFeedFetcher("http_timeout_test", feed{URL: server.URL, Timeout: 10 * time.Millisecond})
+	assert.Error(t, fetcher.fetch(context.Background()))
+	_, err := os.Stat("feed_cache.json")
+	assert.True(t, os.IsNotExist(err), "should not write a cache without a cache path")
+}
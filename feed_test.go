@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFeedCSV(t *testing.T) {
+	rows, err := parseFeedCSV(strings.NewReader(`start,end,value,rate
+2023-12-01T00:30:00Z,2023-12-01T01:00:00Z,0.2,
+2023-12-01T00:00:00Z,2023-12-01T00:30:00Z,0.1,Night
+`), []string{"rate"})
+	require.NoError(t, err)
+	assert.Equal(t, []feedRow{
+		{
+			Start:  time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC),
+			End:    time.Date(2023, 12, 1, 0, 30, 0, 0, time.UTC),
+			Value:  0.1,
+			Labels: map[string]string{"rate": "Night"},
+		},
+		{
+			Start: time.Date(2023, 12, 1, 0, 30, 0, 0, time.UTC),
+			End:   time.Date(2023, 12, 1, 1, 0, 0, 0, time.UTC),
+			Value: 0.2,
+		},
+	}, rows, "should be sorted by start, and empty labels skipped")
+
+	_, err = parseFeedCSV(strings.NewReader("start,value\n"), nil)
+	assert.Equal(t, errors.New(`Feed CSV is missing the "end" column`), err)
+
+	_, err = parseFeedCSV(strings.NewReader("start,end,value\n2023-12-01T00:00:00Z,2023-12-01T00:30:00Z,abc\n"), nil)
+	assert.ErrorContains(t, err, `Error parsing feed CSV row 2 column "value"`)
+
+	_, err = parseFeedCSV(strings.NewReader("start,end,value,tz\n2023-12-01T00:00:00Z,2023-12-01T00:30:00Z,0.1,UTC\n"), []string{"rate"})
+	assert.Equal(t, errors.New(`Feed CSV column "tz" is not a declared label`), err)
+}
+
+func TestParseFeedJSON(t *testing.T) {
+	rows, err := parseFeedJSON([]byte(`[
+		{"start": "2023-12-01T00:00:00+13:00", "end": "2023-12-01T00:30:00+13:00", "value": 0.1, "labels": {"rate": "Night"}}
+	]`), []string{"rate"})
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.True(t, time.Date(2023, 11, 30, 11, 0, 0, 0, time.UTC).Equal(rows[0].Start))
+	assert.Equal(t, 0.1, rows[0].Value)
+	assert.Equal(t, map[string]string{"rate": "Night"}, rows[0].Labels)
+
+	_, err = parseFeedJSON([]byte(`[
+		{"start": "2023-12-01T00:00:00Z", "end": "2023-12-01T01:00:00Z", "value": 0.1},
+		{"start": "2023-12-01T00:30:00Z", "end": "2023-12-01T01:30:00Z", "value": 0.2}
+	]`), nil)
+	assert.Equal(t, errors.New("Feed rows must not overlap. Got: 2023-12-01T00:30:00Z - 2023-12-01T01:00:00Z"), err)
+
+	_, err = parseFeedJSON([]byte(`[{"start": "2023-12-01T01:00:00Z", "end": "2023-12-01T00:00:00Z", "value": 0.1}]`), nil)
+	assert.Equal(t, errors.New("Feed row must end after it starts. Got: 2023-12-01T01:00:00Z - 2023-12-01T00:00:00Z"), err)
+
+	_, err = parseFeedJSON([]byte(`[
+		{"start": "2023-12-01T00:00:00Z", "end": "2023-12-01T00:30:00Z", "value": 0.1, "labels": {"rate": "Night"}}
+	]`), []string{"plan"})
+	assert.Equal(t, errors.New(`Feed row label "rate" is not a declared label`), err)
+}
+
+func TestFeedLabels(t *testing.T) {
+	assert.Equal(t, []string{"rate"}, feedLabels(timeOfUse{VariableLabels: []string{"rate"}, Labels: map[string]string{"plan": "Zappy", "rate": "Day"}}))
+	assert.Equal(t, []string{"plan", "rate"}, feedLabels(timeOfUse{Labels: map[string]string{"rate": "Day", "plan": "Zappy"}}))
+}
+
+func TestCalculateTOUValueFeed(t *testing.T) {
+	tou := timeOfUse{
+		Name:         "feed_value_test",
+		Source:       "file",
+		DefaultValue: 1,
+		TimeWindows: []timeWindow{
+			{Name: "peak", Value: 2, Start: "07:00", End: "09:00", startHour: 7, endHour: 9},
+		},
+	}
+	setFeed(tou.Name, []feedRow{{
+		Start:  time.Date(2023, 12, 1, 8, 0, 0, 0, time.UTC),
+		End:    time.Date(2023, 12, 1, 8, 30, 0, 0, time.UTC),
+		Value:  5,
+		Labels: map[string]string{"rate": "Spot"},
+	}}, time.Now())
+	defer syncFeeds(config{})
+
+	assert.Equal(t, 5.0, calculateTOUValue(tou, time.Date(2023, 12, 1, 8, 0, 0, 0, time.UTC)), "feed should take precedence")
+	assert.Equal(t, 2.0, calculateTOUValue(tou, time.Date(2023, 12, 1, 8, 30, 0, 0, time.UTC)), "should fall back to time windows")
+	assert.Equal(t, 1.0, calculateTOUValue(tou, time.Date(2023, 12, 1, 10, 0, 0, 0, time.UTC)), "should fall back to the default value")
+	assert.Equal(t, map[string]string{"rate": "Spot"}, activeWindowLabels(tou, time.Date(2023, 12, 1, 8, 15, 0, 0, time.UTC)))
+	assert.Equal(t, "feed", activeWindowName(tou, time.Date(2023, 12, 1, 8, 15, 0, 0, time.UTC)))
+
+	integral := 0.0
+	for _, seg := range touSegments(tou, time.Date(2023, 12, 1, 7, 0, 0, 0, time.UTC), time.Date(2023, 12, 1, 9, 0, 0, 0, time.UTC)) {
+		integral += seg.integral()
+	}
+	assert.Equal(t, float64(90*60*2+30*60*5), integral, "segments should split on feed rows")
+}
+
+func TestFeedFileWatcher(t *testing.T) {
+	configFile, err := os.CreateTemp("", "config_test.*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(configFile.Name())
+	feedFile, err := os.CreateTemp("", "feed_test.*.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(feedFile.Name())
+
+	tou := timeOfUse{Name: "feed_watcher_test", Source: "file", Feed: &feed{Path: feedFile.Name()}}
+	defer setLiveConfig(getLiveConfig())
+	setLiveConfig(config{TimeOfUse: []timeOfUse{tou}})
+	defer syncFeeds(config{})
+
+	go configWatcher(configFile.Name())
+	time.Sleep(10 * time.Millisecond) // Give the watcher time to start
+
+	err = os.WriteFile(feedFile.Name(), []byte("start,end,value\n2023-12-01T00:00:00Z,2023-12-01T00:30:00Z,0.1\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond) // Give the watcher time to update
+
+	row, ok := feedRowAt(tou.Name, time.Date(2023, 12, 1, 0, 10, 0, 0, time.UTC))
+	assert.True(t, ok, "feed should be reloaded")
+	assert.Equal(t, 0.1, row.Value)
+
+	// Replace the feed atomically, by renaming a new file over it
+	replacement := feedFile.Name() + ".tmp"
+	err = os.WriteFile(replacement, []byte("start,end,value\n2023-12-01T00:00:00Z,2023-12-01T00:30:00Z,0.2\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(replacement, feedFile.Name()); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond) // Give the watcher time to update
+
+	row, ok = feedRowAt(tou.Name, time.Date(2023, 12, 1, 0, 10, 0, 0, time.UTC))
+	assert.True(t, ok, "feed should be reloaded after being replaced")
+	assert.Equal(t, 0.2, row.Value)
+}
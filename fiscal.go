@@ -0,0 +1,103 @@
+package main
+
+import "time"
+
+// fiscalPatterns are the weeks in each period of a quarter of week based
+// fiscal calendars.
+var fiscalPatterns = map[string][3]int{
+	"4-4-5": {4, 4, 5},
+	"4-5-4": {4, 5, 4},
+	"5-4-4": {5, 4, 4},
+}
+
+// fiscalDate is a date within a fiscal calendar.
+type fiscalDate struct {
+	year    int
+	quarter int
+	// Fiscal month of month based calendars, or period of week based
+	// calendars, from 1-12
+	period int
+	week   int
+}
+
+// yearBounds returns the first day of the fiscal year containing now, and the
+// first day of the next year, at midnight in the location of now, along with
+// the calendar year the fiscal year ends in. Week based years end in the year
+// of the end_month they close, even if their last day is in the next calendar
+// year. Expects a validated fiscal calendar.
+func (fc fiscalCalendar) yearBounds(now time.Time) (time.Time, time.Time, int) {
+	date := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	// Week based years may start in the calendar year before, and end in the
+	// calendar year after, the year they start just after
+	year := now.Year()
+	for date.Before(fc.yearStart(year, now.Location())) {
+		year--
+	}
+	for !date.Before(fc.yearStart(year+1, now.Location())) {
+		year++
+	}
+	start, end := fc.yearStart(year, now.Location()), fc.yearStart(year+1, now.Location())
+	if fc.Pattern != "" {
+		return start, end, year + 1
+	}
+	return start, end, end.AddDate(0, 0, -1).Year()
+}
+
+// yearStart returns the first day of the fiscal year starting in, or for week
+// based calendars starting just after the end of, the given calendar year.
+func (fc fiscalCalendar) yearStart(year int, loc *time.Location) time.Time {
+	if fc.Pattern == "" {
+		return time.Date(year, time.Month(max(fc.StartMonth, 1)), max(fc.StartDay, 1), 0, 0, 0, 0, loc)
+	}
+	return fc.weekYearEnd(year, loc).AddDate(0, 0, 1)
+}
+
+// weekYearEnd returns the last day of the week based fiscal year ending in or
+// around end_month of the given calendar year.
+func (fc fiscalCalendar) weekYearEnd(year int, loc *time.Location) time.Time {
+	monthEnd := time.Date(year, time.Month(fc.EndMonth)+1, 0, 0, 0, 0, 0, loc)
+	back := (int(monthEnd.Weekday()) - fc.EndWeekday + 7) % 7
+	if fc.EndRule == "nearest" && back > 3 {
+		// The following end weekday is nearer, in the next month
+		return monthEnd.AddDate(0, 0, 7-back)
+	}
+	return monthEnd.AddDate(0, 0, -back)
+}
+
+// date returns the fiscal year, quarter, period and week of now.
+func (fc fiscalCalendar) date(now time.Time) fiscalDate {
+	start, _, endYear := fc.yearBounds(now)
+	days := daysBetween(start, now)
+
+	d := fiscalDate{year: endYear, week: days/7 + 1}
+	if fc.YearLabel == "start" {
+		d.year = start.Year()
+		if fc.Pattern != "" {
+			// Week based years start in the month after end_month of the
+			// year before, whichever calendar year their first day is in
+			d.year = endYear - 1
+			if fc.EndMonth == 12 {
+				d.year = endYear
+			}
+		}
+	}
+
+	if fc.Pattern == "" {
+		d.period = (int(now.Month())-int(start.Month())+12)%12 + 1
+		if now.Day() < start.Day() {
+			d.period = (d.period+10)%12 + 1
+		}
+	} else {
+		// 53 week years have an extra week in the last period
+		pattern := fiscalPatterns[fc.Pattern]
+		weeks := 0
+		for d.period = 1; d.period < 12; d.period++ {
+			weeks += pattern[(d.period-1)%3]
+			if d.week <= weeks {
+				break
+			}
+		}
+	}
+	d.quarter = (d.period-1)/3 + 1
+	return d
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFiscalDate(t *testing.T) {
+	auckland, err := time.LoadLocation("Pacific/Auckland")
+	if err != nil {
+		t.Fatal(err)
+	}
+	april := fiscalCalendar{Name: "april", StartMonth: 4, StartDay: 1}
+	// National Retail Federation 4-5-4 calendar, ending on the Saturday
+	// nearest the end of January, numbered by the year it starts in
+	nrf := fiscalCalendar{Name: "nrf", Pattern: "4-5-4", EndMonth: 1, EndWeekday: 6, EndRule: "nearest", YearLabel: "start"}
+
+	testCases := map[string]struct {
+		calendar fiscalCalendar
+		now      time.Time
+		expected fiscalDate
+	}{
+		"date based": {
+			calendar: april,
+			now:      time.Date(2024, 5, 10, 12, 0, 0, 0, auckland),
+			expected: fiscalDate{year: 2025, quarter: 1, period: 2, week: 6},
+		},
+		"date based, last day": {
+			calendar: april,
+			now:      time.Date(2024, 3, 31, 23, 59, 0, 0, auckland),
+			expected: fiscalDate{year: 2024, quarter: 4, period: 12, week: 53},
+		},
+		"date based, start label": {
+			calendar: fiscalCalendar{Name: "april", StartMonth: 4, StartDay: 1, YearLabel: "start"},
+			now:      time.Date(2024, 3, 31, 0, 0, 0, 0, auckland),
+			expected: fiscalDate{year: 2023, quarter: 4, period: 12, week: 53},
+		},
+		"date based, mid month start": {
+			calendar: fiscalCalendar{Name: "july", StartMonth: 7, StartDay: 15},
+			now:      time.Date(2024, 8, 14, 0, 0, 0, 0, auckland),
+			expected: fiscalDate{year: 2025, quarter: 1, period: 1, week: 5},
+		},
+		"calendar year": {
+			calendar: fiscalCalendar{Name: "calendar"},
+			now:      time.Date(2024, 12, 31, 0, 0, 0, 0, auckland),
+			expected: fiscalDate{year: 2024, quarter: 4, period: 12, week: 53},
+		},
+		"week based, first day": {
+			calendar: nrf,
+			now:      time.Date(2023, 1, 29, 0, 0, 0, 0, auckland),
+			expected: fiscalDate{year: 2023, quarter: 1, period: 1, week: 1},
+		},
+		"week based, second period": {
+			calendar: nrf,
+			now:      time.Date(2023, 3, 5, 0, 0, 0, 0, auckland),
+			expected: fiscalDate{year: 2023, quarter: 1, period: 2, week: 6},
+		},
+		"week based, 53rd week": {
+			calendar: nrf,
+			now:      time.Date(2024, 2, 3, 12, 0, 0, 0, auckland),
+			expected: fiscalDate{year: 2023, quarter: 4, period: 12, week: 53},
+		},
+		"week based, after 53 week year": {
+			calendar: nrf,
+			now:      time.Date(2024, 2, 4, 0, 0, 0, 0, auckland),
+			expected: fiscalDate{year: 2024, quarter: 1, period: 1, week: 1},
+		},
+		"week based, last weekday": {
+			calendar: fiscalCalendar{Name: "last", Pattern: "5-4-4", EndMonth: 12, EndWeekday: 0, EndRule: "last"},
+			now:      time.Date(2024, 1, 1, 0, 0, 0, 0, auckland),
+			expected: fiscalDate{year: 2024, quarter: 1, period: 1, week: 1},
+		},
+	}
+
+	for name, tc := range testCases {
+		assert.Equal(t, tc.expected, tc.calendar.date(tc.now), name)
+	}
+}
+
+func TestFiscalYearBoundsNearestInNextYear(t *testing.T) {
+	// The year ending on the Saturday nearest the end of December 2025 ends on
+	// 3 January 2026, so started two calendar years before
+	fc := fiscalCalendar{Name: "nearest", Pattern: "4-4-5", EndMonth: 12, EndWeekday: 6, EndRule: "nearest"}
+	now := time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)
+
+	start, end, year := fc.yearBounds(now)
+	assert.Equal(t, time.Date(2024, 12, 29, 0, 0, 0, 0, time.UTC), start)
+	assert.Equal(t, time.Date(2026, 1, 4, 0, 0, 0, 0, time.UTC), end)
+	assert.Equal(t, 2025, year)
+	assert.Equal(t, fiscalDate{year: 2025, quarter: 4, period: 12, week: 53}, fc.date(now))
+
+	start, _, year = fc.yearBounds(time.Date(2026, 1, 4, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2026, 1, 4, 0, 0, 0, 0, time.UTC), start)
+	assert.Equal(t, 2026, year)
+}
+
+func TestFiscalYearLabelsConsecutive(t *testing.T) {
+	calendars := []fiscalCalendar{
+		{Name: "april", StartMonth: 4, StartDay: 1},
+		{Name: "april_start", StartMonth: 4, StartDay: 1, YearLabel: "start"},
+		{Name: "calendar"},
+		{Name: "december_nearest", Pattern: "4-4-5", EndMonth: 12, EndWeekday: 6, EndRule: "nearest"},
+		{Name: "december_nearest_start", Pattern: "4-4-5", EndMonth: 12, EndWeekday: 6, EndRule: "nearest", YearLabel: "start"},
+		{Name: "december_last", Pattern: "5-4-4", EndMonth: 12, EndWeekday: 0, EndRule: "last"},
+		{Name: "nrf", Pattern: "4-5-4", EndMonth: 1, EndWeekday: 6, EndRule: "nearest", YearLabel: "start"},
+		{Name: "june_last", Pattern: "4-4-5", EndMonth: 6, EndWeekday: 5, EndRule: "last"},
+	}
+
+	for _, fc := range calendars {
+		start, _, _ := fc.yearBounds(time.Date(2000, 6, 1, 0, 0, 0, 0, time.UTC))
+		previous := fc.date(start).year
+		for i := 0; i < 50; i++ {
+			_, next, _ := fc.yearBounds(start)
+			year := fc.date(next).year
+			assert.Equal(t, previous+1, year, "%s year starting %s", fc.Name, next.Format(time.DateOnly))
+			start, previous = next, year
+		}
+	}
+}
+
+func TestCollectFiscalCalendars(t *testing.T) {
+	defer setLiveConfig(getLiveConfig())
+	setLiveConfig(config{FiscalCalendars: []fiscalCalendar{
+		{Name: "april", Timezone: "Pacific/Auckland", StartMonth: 4, StartDay: 1},
+	}})
+
+	testCh := make(chan prometheus.Metric)
+	go func() {
+		// 2024-04-01 00:30 in Auckland
+		collectFiscalCalendars(testCh, getLiveConfig(), time.Date(2024, 3, 31, 11, 30, 0, 0, time.UTC))
+		close(testCh)
+	}()
+
+	values := []float64{}
+	for m := range testCh {
+		actual := &dto.Metric{}
+		if err := m.Write(actual); err != nil {
+			t.Fatal(err)
+		}
+		for _, l := range actual.GetLabel() {
+			switch l.GetName() {
+			case "calendar":
+				assert.Equal(t, "april", l.GetValue())
+			case "tz":
+				assert.Equal(t, "Pacific/Auckland", l.GetValue())
+			}
+		}
+		values = append(values, actual.GetGauge().GetValue())
+	}
+	assert.Equal(t, []float64{2025, 1, 1, 1}, values, "year, quarter, period and week")
+}
+
+func TestValidateFiscalCalendar(t *testing.T) {
+	assert.NoError(t, validateFiscalCalendar(fiscalCalendar{Name: "april", StartMonth: 4, StartDay: 1}, nil))
+	assert.NoError(t, validateFiscalCalendar(fiscalCalendar{Name: "nrf", Pattern: "4-5-4", EndMonth: 1, EndWeekday: 6, EndRule: "nearest"}, nil))
+	assert.Equal(t, errors.New("Fiscal calendars must have a name"), validateFiscalCalendar(fiscalCalendar{}, nil))
+	assert.Equal(t, errors.New(`Duplicate fiscal calendar name "april"`),
+		validateFiscalCalendar(fiscalCalendar{Name: "april", StartMonth: 4, StartDay: 1}, []fiscalCalendar{{Name: "april", StartMonth: 4, StartDay: 6}}))
+	assert.Equal(t, errors.New("Fiscal calendar start_day must be a day of start_month. Got: 29"),
+		validateFiscalCalendar(fiscalCalendar{Name: "feb", StartMonth: 2, StartDay: 29}, nil))
+	assert.Equal(t, errors.New(`Unknown fiscal calendar pattern "4-4-4". Must be 4-4-5, 4-5-4 or 5-4-4`),
+		validateFiscalCalendar(fiscalCalendar{Name: "weeks", Pattern: "4-4-4", EndMonth: 1}, nil))
+	assert.Equal(t, errors.New("Fiscal calendar end_month must be 1-12. Got: 0"),
+		validateFiscalCalendar(fiscalCalendar{Name: "weeks", Pattern: "4-4-5"}, nil))
+	assert.Equal(t, errors.New("Fiscal calendars with a pattern can't have start_month or start_day"),
+		validateFiscalCalendar(fiscalCalendar{Name: "weeks", Pattern: "4-4-5", EndMonth: 1, StartMonth: 4}, nil))
+	assert.Equal(t, errors.New("Fiscal calendars without a pattern can't have end_month, end_weekday or end_rule"),
+		validateFiscalCalendar(fiscalCalendar{Name: "april", StartMonth: 4, EndMonth: 3}, nil))
+	assert.Equal(t, errors.New(`Unknown fiscal calendar end_rule "first". Must be last or nearest`),
+		validateFiscalCalendar(fiscalCalendar{Name: "weeks", Pattern: "4-4-5", EndMonth: 1, EndRule: "first"}, nil))
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// touIntegral accumulates the value of a time of use over time, and the time
+// spent in each time window.
+type touIntegral struct {
+	last          time.Time
+	integral      float64
+	windowSeconds map[string]float64
+}
+
+var (
+	// Integrals are accumulated from when the exporter started
+	processStart   = time.Now()
+	touIntegrals   = map[string]*touIntegral{}
+	touIntegralsMu sync.Mutex
+)
+
+// updateTOUIntegral advances the integral of a time of use up to now, and
+// returns a copy of the updated state. The integral is calculated from the
+// schedule, so it doesn't depend on how often it's updated.
+func updateTOUIntegral(tou timeOfUse, now time.Time) touIntegral {
+	touIntegralsMu.Lock()
+	defer touIntegralsMu.Unlock()
+
+	state, ok := touIntegrals[tou.Name]
+	if !ok {
+		state = &touIntegral{
+			last:          processStart,
+			windowSeconds: map[string]float64{},
+		}
+		touIntegrals[tou.Name] = state
+	}
+
+	for _, seg := range touSegments(tou, state.last.In(now.Location()), now) {
+		state.windowSeconds[seg.window] += seg.seconds()
+		if seg.matched || !tou.AbsentWhenUnmatched {
+			state.integral += seg.integral()
+		}
+	}
+	if now.After(state.last) {
+		state.last = now
+	}
+
+	windowSeconds := make(map[string]float64, len(state.windowSeconds))
+	for k, v := range state.windowSeconds {
+		windowSeconds[k] = v
+	}
+	return touIntegral{last: state.last, integral: state.integral, windowSeconds: windowSeconds}
+}
+
+func describeIntegralMetrics(n metricNaming, tou timeOfUse) (*prometheus.Desc, *prometheus.Desc) {
+	return prometheus.NewDesc(
+			n.touMetricName(tou)+"_integral_total",
+			"Integral of "+tou.Name+" over time since the exporter started, in value-seconds",
+			nil,
+			n.touConstLabels(tou),
+		), prometheus.NewDesc(
+			n.touMetricName(tou)+"_window_seconds_total",
+			"Seconds spent in each time window of "+tou.Name+" since the exporter started",
+			[]string{touWindowLabel},
+			n.touConstLabels(tou),
+		)
+}
+
+func collectIntegralMetrics(ch chan<- prometheus.Metric, n metricNaming, tou timeOfUse, now time.Time) {
+	integralDesc, windowSecondsDesc := describeIntegralMetrics(n, tou)
+	state := updateTOUIntegral(tou, now)
+
+	ch <- prometheus.MustNewConstMetric(integralDesc, prometheus.CounterValue, state.integral)
+	for _, window := range touWindowStates(tou) {
+		ch <- prometheus.MustNewConstMetric(windowSecondsDesc, prometheus.CounterValue, state.windowSeconds[window], window)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateTOUIntegral(t *testing.T) {
+	tou := timeOfUse{
+		Name:            "integral_test",
+		DefaultValue:    1,
+		IntegralMetrics: true,
+		TimeWindows: []timeWindow{
+			{Name: "peak", Value: 3, Start: "07:00", End: "09:00", startHour: 7, endHour: 9},
+		},
+	}
+	touIntegrals[tou.Name] = &touIntegral{
+		last:          time.Date(2023, 12, 1, 6, 0, 0, 0, time.UTC),
+		windowSeconds: map[string]float64{},
+	}
+	defer delete(touIntegrals, tou.Name)
+
+	// Irregular update intervals should give the same result as a single update
+	updateTOUIntegral(tou, time.Date(2023, 12, 1, 6, 59, 0, 0, time.UTC))
+	updateTOUIntegral(tou, time.Date(2023, 12, 1, 8, 30, 0, 0, time.UTC))
+	state := updateTOUIntegral(tou, time.Date(2023, 12, 1, 10, 0, 0, 0, time.UTC))
+
+	assert.Equal(t, float64(2*60*60*1+2*60*60*3), state.integral)
+	assert.Equal(t, map[string]float64{"default": 2 * 60 * 60, "peak": 2 * 60 * 60}, state.windowSeconds)
+
+	state = updateTOUIntegral(tou, time.Date(2023, 12, 1, 9, 0, 0, 0, time.UTC))
+	assert.Equal(t, float64(2*60*60*1+2*60*60*3), state.integral, "should not go backwards in time")
+}
+
+func TestUpdateTOUIntegralAbsentWhenUnmatched(t *testing.T) {
+	tou := timeOfUse{
+		Name:                "integral_absent_test",
+		DefaultValue:        1,
+		AbsentWhenUnmatched: true,
+		TimeWindows: []timeWindow{
+			{Name: "event", Value: 3, Start: "07:00", End: "09:00", startHour: 7, endHour: 9},
+		},
+	}
+	touIntegrals[tou.Name] = &touIntegral{
+		last:          time.Date(2023, 12, 1, 6, 0, 0, 0, time.UTC),
+		windowSeconds: map[string]float64{},
+	}
+	defer delete(touIntegrals, tou.Name)
+
+	state := updateTOUIntegral(tou, time.Date(2023, 12, 1, 10, 0, 0, 0, time.UTC))
+	assert.Equal(t, float64(2*60*60*3), state.integral, "unmatched time should not contribute")
+}
+
+func TestCollectIntegralMetrics(t *testing.T) {
+	tou := timeOfUse{
+		Name:            "integral_collect_test",
+		DefaultValue:    1,
+		IntegralMetrics: true,
+		TimeWindows: []timeWindow{
+			{Name: "peak", Value: 3, Start: "07:00", End: "09:00", startHour: 7, endHour: 9},
+		},
+	}
+	touIntegrals[tou.Name] = &touIntegral{
+		last:          time.Date(2023, 12, 1, 8, 0, 0, 0, time.UTC),
+		windowSeconds: map[string]float64{},
+	}
+	defer delete(touIntegrals, tou.Name)
+
+	testCh := make(chan prometheus.Metric)
+	go func() {
+		collectIntegralMetrics(testCh, metricNaming{}, tou, time.Date(2023, 12, 1, 9, 30, 0, 0, time.UTC))
+		close(testCh)
+	}()
+
+	values := map[string]float64{}
+	for m := range testCh {
+		actual := &dto.Metric{}
+		if err := m.Write(actual); err != nil {
+			t.Fatal(err)
+		}
+		key := "integral"
+		for _, l := range actual.GetLabel() {
+			if l.GetName() == "window" {
+				key = l.GetValue()
+			}
+		}
+		values[key] = actual.GetCounter().GetValue()
+	}
+
+	assert.Equal(t, map[string]float64{
+		"integral": 60*60*3 + 30*60*1,
+		"peak":     60 * 60,
+		"default":  30 * 60,
+	}, values)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"slices"
+	"time"
+)
+
+// Locale of day and month names when none is configured
+const defaultLocale = "en"
+
+// localeNames are the names of the days of the week, from Sunday, and months
+// of the year, from January, in a locale.
+type localeNames struct {
+	weekdays [7]string
+	months   [12]string
+}
+
+var locales = map[string]localeNames{
+	"en": {
+		weekdays: [7]string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"},
+		months:   [12]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+	},
+	"de": {
+		weekdays: [7]string{"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"},
+		months:   [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+	},
+	"fr": {
+		weekdays: [7]string{"dimanche", "lundi", "mardi", "mercredi", "jeudi", "vendredi", "samedi"},
+		months:   [12]string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+	},
+	"es": {
+		weekdays: [7]string{"domingo", "lunes", "martes", "miércoles", "jueves", "viernes", "sábado"},
+		months:   [12]string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+	},
+	"ja": {
+		weekdays: [7]string{"日曜日", "月曜日", "火曜日", "水曜日", "木曜日", "金曜日", "土曜日"},
+		months:   [12]string{"1月", "2月", "3月", "4月", "5月", "6月", "7月", "8月", "9月", "10月", "11月", "12月"},
+	},
+	"mi": {
+		weekdays: [7]string{"Rātapu", "Rāhina", "Rātū", "Rāapa", "Rāpare", "Rāmere", "Rāhoroi"},
+		months:   [12]string{"Kohitātea", "Huitanguru", "Poutūterangi", "Paengawhāwhā", "Haratua", "Pipiri", "Hōngongoi", "Hereturikōkā", "Mahuru", "Whiringa-ā-nuku", "Whiringa-ā-rangi", "Hakihea"},
+	},
+	"zh": {
+		weekdays: [7]string{"星期日", "星期一", "星期二", "星期三", "星期四", "星期五", "星期六"},
+		months:   [12]string{"一月", "二月", "三月", "四月", "五月", "六月", "七月", "八月", "九月", "十月", "十一月", "十二月"},
+	},
+}
+
+// localeNamesFor returns the names of a locale, defaulting to English.
+func localeNamesFor(locale string) localeNames {
+	if locale == "" {
+		locale = defaultLocale
+	}
+	return locales[locale]
+}
+
+func (n localeNames) weekday(d time.Weekday) string {
+	return n.weekdays[d]
+}
+
+func (n localeNames) month(m time.Month) string {
+	return n.months[m-1]
+}
+
+// localeCodes returns the supported locales in order.
+func localeCodes() []string {
+	codes := make([]string, 0, len(locales))
+	for code := range locales {
+		codes = append(codes, code)
+	}
+	slices.Sort(codes)
+	return codes
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocaleNames(t *testing.T) {
+	for code, names := range locales {
+		for d := time.Sunday; d <= time.Saturday; d++ {
+			assert.NotEmpty(t, names.weekday(d), "%s %s", code, d)
+		}
+		for m := time.January; m <= time.December; m++ {
+			assert.NotEmpty(t, names.month(m), "%s %s", code, m)
+		}
+	}
+
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		assert.Equal(t, d.String(), localeNamesFor("").weekday(d))
+	}
+	for m := time.January; m <= time.December; m++ {
+		assert.Equal(t, m.String(), localeNamesFor("").month(m))
+	}
+
+	assert.Equal(t, "Donnerstag", localeNamesFor("de").weekday(time.Thursday))
+	assert.Equal(t, "août", localeNamesFor("fr").month(time.August))
+	assert.Equal(t, "miércoles", localeNamesFor("es").weekday(time.Wednesday))
+	assert.Equal(t, "12月", localeNamesFor("ja").month(time.December))
+	assert.Equal(t, "Rāhina", localeNamesFor("mi").weekday(time.Monday))
+	assert.Equal(t, "十一月", localeNamesFor("zh").month(time.November))
+}
+
+func TestValidateLocale(t *testing.T) {
+	assert.NoError(t, validateLocale(""))
+	assert.NoError(t, validateLocale("mi"))
+	assert.EqualError(t, validateLocale("EN"), `Unknown locale "EN". Must be one of de, en, es, fr, ja, mi, zh`)
+}
+
+func TestCollectLocalizedTimezonesLocale(t *testing.T) {
+	defer setLiveConfig(getLiveConfig())
+	setLiveConfig(config{LocalizedTimezones: []localizedTimezone{
+		{Timezone: "Pacific/Auckland", Metrics: []string{"day_of_week", "month"}, Locale: "mi"},
+		{Timezone: "Europe/Berlin", Metrics: []string{"day_of_week", "month"}, Locale: "de"},
+	}})
+
+	testCh := make(chan prometheus.Metric)
+	go func() {
+		// Wednesday 1 February in Auckland, Tuesday 31 January in Berlin
+		collectLocalizedTimezones(testCh, getLiveConfig(), time.Date(2023, 1, 31, 20, 3, 4, 0, time.UTC))
+		close(testCh)
+	}()
+
+	names := map[string]string{}
+	for m := range testCh {
+		actual := &dto.Metric{}
+		if err := m.Write(actual); err != nil {
+			t.Fatal(err)
+		}
+		_, after, _ := strings.Cut(m.Desc().String(), "tou_exporter_localized_")
+		labels := map[string]string{}
+		for _, l := range actual.GetLabel() {
+			labels[l.GetName()] = l.GetValue()
+		}
+		family := strings.Split(after, `"`)[0]
+		names[labels["tz"]+" "+family] = labels[map[string]string{"day_of_week": "day", "month": "month"}[family]]
+	}
+
+	assert.Equal(t, map[string]string{
+		"Pacific/Auckland day_of_week": "Rāapa",
+		"Pacific/Auckland month":       "Huitanguru",
+		"Europe/Berlin day_of_week":    "Dienstag",
+		"Europe/Berlin month":          "Januar",
+	}, names)
+}
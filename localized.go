@@ -0,0 +1,146 @@
+package main
+
+import (
+	"slices"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// localizedFamily is a family of localized calendar metrics, with the value
+// and any extra label values at a local time.
+type localizedFamily struct {
+	name string
+	help string
+	// Labels after the timezone label
+	labels    []string
+	isDefault bool
+	value     func(t time.Time) (float64, []string)
+	// Whether there's a value at a local time. Always if unset
+	present func(t time.Time) bool
+	// Label values which are names in the locale of the timezone, used instead
+	// of those from value when set
+	names func(t time.Time, n localeNames) []string
+}
+
+// desc builds the desc of a localized family, named and labelled by the
+// metric naming config.
+func (f localizedFamily) desc(n metricNaming) *prometheus.Desc {
+	labels := []string{n.timezoneLabel()}
+	for _, l := range f.labels {
+		if !n.dropsLabel(l) {
+			labels = append(labels, l)
+		}
+	}
+	return prometheus.NewDesc(n.namespace()+"_localized_"+f.name, f.help, labels, nil)
+}
+
+// labelValues returns the values of the labels of a localized family, without
+// any dropped by the metric naming config.
+func (f localizedFamily) labelValues(n metricNaming, tz string, values []string) []string {
+	labelValues := []string{n.timezoneValue(tz)}
+	for i, l := range f.labels {
+		if !n.dropsLabel(l) {
+			labelValues = append(labelValues, values[i])
+		}
+	}
+	return labelValues
+}
+
+var localizedFamilies = []localizedFamily{
+	{name: "minute", help: "Minute of the hour from 0-59 in a specific timezone", isDefault: true, value: func(t time.Time) (float64, []string) { return float64(t.Minute()), nil }},
+	{name: "hour", help: "Hour of the day from 0-23 in a specific timezone", isDefault: true, value: func(t time.Time) (float64, []string) { return float64(t.Hour()), nil }},
+	{name: "day_of_week", help: "Day of the week from 0-6 in a specific timezone. 0 is Sunday.", labels: []string{"day"}, isDefault: true, value: func(t time.Time) (float64, []string) {
+		return float64(t.Weekday()), nil
+	}, names: func(t time.Time, n localeNames) []string {
+		return []string{n.weekday(t.Weekday())}
+	}},
+	{name: "day_of_month", help: "Day of the month from 1-31 in a specific timezone", isDefault: true, value: func(t time.Time) (float64, []string) { return float64(t.Day()), nil }},
+	{name: "month", help: "Month of the year from 1-12 in a specific timezone", labels: []string{"month"}, isDefault: true, value: func(t time.Time) (float64, []string) {
+		return float64(t.Month()), nil
+	}, names: func(t time.Time, n localeNames) []string {
+		return []string{n.month(t.Month())}
+	}},
+	{name: "utc_offset_seconds", help: "Offset from UTC in seconds in a specific timezone", isDefault: true, value: func(t time.Time) (float64, []string) {
+		_, offset := t.Zone()
+		return float64(offset), nil
+	}},
+	{name: "is_dst", help: "1 while daylight saving time is observed in a specific timezone, otherwise 0", isDefault: true, value: func(t time.Time) (float64, []string) {
+		if t.IsDST() {
+			return 1, nil
+		}
+		return 0, nil
+	}},
+	{name: "zone_info", help: "Always 1. The abbreviation label is the current zone abbreviation in a specific timezone", labels: []string{"abbreviation"}, isDefault: true, value: func(t time.Time) (float64, []string) {
+		abbreviation, _ := t.Zone()
+		return 1, []string{abbreviation}
+	}},
+	{name: "previous_transition_timestamp_seconds", help: "Unix timestamp of the last zone transition in a specific timezone, such as the start of daylight saving time", isDefault: true, value: func(t time.Time) (float64, []string) {
+		start, _ := t.ZoneBounds()
+		return float64(start.Unix()), nil
+	}, present: func(t time.Time) bool {
+		start, _ := t.ZoneBounds()
+		return !start.IsZero()
+	}},
+	{name: "next_transition_timestamp_seconds", help: "Unix timestamp of the next zone transition in a specific timezone, such as the end of daylight saving time", isDefault: true, value: func(t time.Time) (float64, []string) {
+		_, end := t.ZoneBounds()
+		return float64(end.Unix()), nil
+	}, present: func(t time.Time) bool {
+		_, end := t.ZoneBounds()
+		return !end.IsZero()
+	}},
+	{name: "year", help: "Year in a specific timezone", isDefault: false, value: func(t time.Time) (float64, []string) {
+		return float64(t.Year()), nil
+	}},
+	{name: "day_of_year", help: "Day of the year from 1-366 in a specific timezone", isDefault: false, value: func(t time.Time) (float64, []string) {
+		return float64(t.YearDay()), nil
+	}},
+	{name: "iso_week", help: "ISO 8601 week of the year from 1-53 in a specific timezone", isDefault: false, value: func(t time.Time) (float64, []string) {
+		_, week := t.ISOWeek()
+		return float64(week), nil
+	}},
+	{name: "iso_year", help: "ISO 8601 year of the ISO week in a specific timezone", isDefault: false, value: func(t time.Time) (float64, []string) {
+		year, _ := t.ISOWeek()
+		return float64(year), nil
+	}},
+	{name: "quarter", help: "Quarter of the year from 1-4 in a specific timezone", isDefault: false, value: func(t time.Time) (float64, []string) {
+		return float64((t.Month()-1)/3 + 1), nil
+	}},
+	{name: "week_of_month", help: "Week of the month from 1-5 in a specific timezone. Weeks start on the 1st, 8th, 15th, 22nd and 29th, so 2 on a Tuesday is the second Tuesday of the month", isDefault: false, value: func(t time.Time) (float64, []string) {
+		return float64((t.Day()-1)/7 + 1), nil
+	}},
+	{name: "days_in_month", help: "Number of days in the current month in a specific timezone", isDefault: false, value: func(t time.Time) (float64, []string) {
+		return float64(daysInMonth(t.Year(), t.Month())), nil
+	}},
+	{name: "minute_of_day", help: "Minute of the day from 0-1439 on the wall clock in a specific timezone", isDefault: false, value: func(t time.Time) (float64, []string) {
+		return float64(t.Hour()*60 + t.Minute()), nil
+	}},
+	{name: "seconds_since_midnight", help: "Seconds elapsed since midnight in a specific timezone. Differs from the wall clock on daylight saving transition days", isDefault: false, value: func(t time.Time) (float64, []string) {
+		midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+		return t.Sub(midnight).Seconds(), nil
+	}},
+	{name: "is_weekend", help: "1 on Saturday and Sunday in a specific timezone, otherwise 0", isDefault: false, value: func(t time.Time) (float64, []string) {
+		if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+			return 1, nil
+		}
+		return 0, nil
+	}},
+}
+
+// valueAt returns the value and label values of a localized family at a local
+// time, with names in the locale of the timezone.
+func (l localizedTimezone) valueAt(f localizedFamily, t time.Time) (float64, []string) {
+	v, labels := f.value(t)
+	if f.names != nil {
+		labels = f.names(t, localeNamesFor(l.Locale))
+	}
+	return v, labels
+}
+
+// enabled returns whether a localized timezone exposes a metric family.
+func (l localizedTimezone) enabled(f localizedFamily) bool {
+	if len(l.Metrics) == 0 {
+		return f.isDefault
+	}
+	return slices.Contains(l.Metrics, f.name)
+}
@@ -2,23 +2,61 @@ package main
 
 import (
 	"log/slog"
+	"slices"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 var (
-	// Localized timezones
-	minuteLocalized     = prometheus.NewDesc("tou_exporter_localized_minute", "Minute of the hour from 0-59 in a specific timezone", []string{"tz"}, nil)
-	hourLocalized       = prometheus.NewDesc("tou_exporter_localized_hour", "Hour of the day from 0-23 in a specific timezone", []string{"tz"}, nil)
-	dayOfWeekLocalized  = prometheus.NewDesc("tou_exporter_localized_day_of_week", "Day of the week from 0-6 in a specific timezone. 0 is Sunday.", []string{"tz", "day"}, nil)
-	dayOfMonthLocalized = prometheus.NewDesc("tou_exporter_localized_day_of_month", "Day of the month from 1-31 in a specific timezone", []string{"tz"}, nil)
-	monthLocalized      = prometheus.NewDesc("tou_exporter_localized_month", "Month of the year from 1-12 in a specific timezone", []string{"tz", "month"}, nil)
+	// Billing cycles
+	billingCycleDay        = exporterMetric{name: "billing_cycle_day", help: "Day of the current billing cycle, starting from 1", labels: []string{"cycle", defaultTimezoneLabel}}
+	billingCycleLengthDays = exporterMetric{name: "billing_cycle_length_days", help: "Length of the current billing cycle in days", labels: []string{"cycle", defaultTimezoneLabel}}
+	billingCycleStart      = exporterMetric{name: "billing_cycle_start_timestamp_seconds", help: "Unix timestamp of the start of the current billing cycle", labels: []string{"cycle", defaultTimezoneLabel}}
+	billingCycleID         = exporterMetric{name: "billing_cycle_id", help: "Always 1. The id label is the start date of the current billing cycle", labels: []string{"cycle", defaultTimezoneLabel, "id"}}
+
+	// Fiscal calendars
+	fiscalYear    = exporterMetric{name: "fiscal_year", help: "Fiscal year of a fiscal calendar", labels: []string{"calendar", defaultTimezoneLabel}}
+	fiscalQuarter = exporterMetric{name: "fiscal_quarter", help: "Fiscal quarter from 1-4 of a fiscal calendar", labels: []string{"calendar", defaultTimezoneLabel}}
+	fiscalPeriod  = exporterMetric{name: "fiscal_period", help: "Fiscal month or period from 1-12 of a fiscal calendar", labels: []string{"calendar", defaultTimezoneLabel}}
+	fiscalWeek    = exporterMetric{name: "fiscal_week", help: "Week of the fiscal year from 1-53 of a fiscal calendar", labels: []string{"calendar", defaultTimezoneLabel}}
+
+	// Solar events
+	solarLabels           = []string{"location", defaultTimezoneLabel}
+	solarSunrise          = exporterMetric{name: "solar_sunrise_timestamp_seconds", help: "Unix timestamp of sunrise today at a location. Absent during polar day and night", labels: solarLabels}
+	solarSunset           = exporterMetric{name: "solar_sunset_timestamp_seconds", help: "Unix timestamp of sunset today at a location. Absent during polar day and night", labels: solarLabels}
+	solarCivilDawn        = exporterMetric{name: "solar_civil_dawn_timestamp_seconds", help: "Unix timestamp of the start of civil twilight today at a location, when the sun is 6 degrees below the horizon", labels: solarLabels}
+	solarCivilDusk        = exporterMetric{name: "solar_civil_dusk_timestamp_seconds", help: "Unix timestamp of the end of civil twilight today at a location, when the sun is 6 degrees below the horizon", labels: solarLabels}
+	solarNauticalDawn     = exporterMetric{name: "solar_nautical_dawn_timestamp_seconds", help: "Unix timestamp of the start of nautical twilight today at a location, when the sun is 12 degrees below the horizon", labels: solarLabels}
+	solarNauticalDusk     = exporterMetric{name: "solar_nautical_dusk_timestamp_seconds", help: "Unix timestamp of the end of nautical twilight today at a location, when the sun is 12 degrees below the horizon", labels: solarLabels}
+	solarNoonTimestamp    = exporterMetric{name: "solar_noon_timestamp_seconds", help: "Unix timestamp of solar noon today at a location", labels: solarLabels}
+	solarDaylightDuration = exporterMetric{name: "solar_daylight_seconds", help: "Seconds between sunrise and sunset today at a location", labels: solarLabels}
+	solarElevationDegrees = exporterMetric{name: "solar_elevation_degrees", help: "Current elevation of the sun above the horizon at a location, in degrees", labels: solarLabels}
+	solarIsDaylight       = exporterMetric{name: "solar_is_daylight", help: "1 between sunrise and sunset at a location, otherwise 0", labels: solarLabels}
+
+	// Price feeds
+	feedLastUpdate  = exporterMetric{name: "feed_last_update_timestamp_seconds", help: "Unix timestamp of the last successful feed update", labels: []string{"name", "source"}}
+	feedCoverageEnd = exporterMetric{name: "feed_coverage_end_timestamp_seconds", help: "Unix timestamp of the end of the last row in the feed", labels: []string{"name", "source"}}
+
+	exporterMetrics = []exporterMetric{
+		billingCycleDay, billingCycleLengthDays, billingCycleStart, billingCycleID,
+		fiscalYear, fiscalQuarter, fiscalPeriod, fiscalWeek,
+		solarSunrise, solarSunset, solarCivilDawn, solarCivilDusk, solarNauticalDawn, solarNauticalDusk,
+		solarNoonTimestamp, solarDaylightDuration, solarElevationDegrees, solarIsDaylight,
+		feedLastUpdate, feedCoverageEnd,
+	}
 )
 
 func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
-	describeLocalizedTimezones(ch)
-	describeTOUMetrics(ch)
+	// Describe and collect with one snapshot of the config, so a reload can't
+	// mix the metric naming of two configs
+	c := getLiveConfig()
+	describeLocalizedTimezones(ch, c)
+	describeBillingCycles(ch, c)
+	describeFiscalCalendars(ch, c)
+	describeLocations(ch, c)
+	describeTOUMetrics(ch, c)
+	describeFixedCharges(ch, c)
 }
 
 func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
@@ -29,60 +67,243 @@ func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
 	}
 	t := time.Now().In(utc)
 
-	collectLocalizedTimezones(ch, t)
-	collectTOUMetrics(ch, t)
+	c := getLiveConfig()
+	collectLocalizedTimezones(ch, c, t)
+	collectBillingCycles(ch, c, t)
+	collectFiscalCalendars(ch, c, t)
+	collectLocations(ch, c, t)
+	collectTOUMetrics(ch, c, t)
+	collectFixedCharges(ch, c, t)
+}
+
+func describeLocalizedTimezones(ch chan<- *prometheus.Desc, c config) {
+	n := c.MetricNaming
+	for _, f := range localizedFamilies {
+		if f.isDefault || slices.ContainsFunc(c.LocalizedTimezones, func(l localizedTimezone) bool { return l.enabled(f) }) {
+			ch <- f.desc(n)
+		}
+	}
+}
+
+func collectLocalizedTimezones(ch chan<- prometheus.Metric, c config, utcNow time.Time) {
+	n := c.MetricNaming
+	for _, tz := range c.LocalizedTimezones {
+		slog.Debug("Collecting localized timezone", "tz", tz.Timezone)
+		loc, err := loadLocation(tz.Timezone)
+		if err != nil {
+			slog.Error("error loading timezone", "tz", tz.Timezone, "err", err)
+			continue
+		}
+		for _, f := range localizedFamilies {
+			if !tz.enabled(f) || (f.present != nil && !f.present(utcNow.In(loc))) {
+				continue
+			}
+			v, labels := tz.valueAt(f, utcNow.In(loc))
+			ch <- prometheus.MustNewConstMetric(f.desc(n), prometheus.GaugeValue, v, f.labelValues(n, tz.Timezone, labels)...)
+		}
+	}
+}
+
+func describeBillingCycles(ch chan<- *prometheus.Desc, c config) {
+	n := c.MetricNaming
+	ch <- billingCycleDay.desc(n)
+	ch <- billingCycleLengthDays.desc(n)
+	ch <- billingCycleStart.desc(n)
+	ch <- billingCycleID.desc(n)
+}
+
+func collectBillingCycles(ch chan<- prometheus.Metric, c config, utcNow time.Time) {
+	n := c.MetricNaming
+	for _, bc := range c.BillingCycles {
+		slog.Debug("Collecting billing cycle", "cycle", bc.Name)
+		loc, err := loadLocation(bc.Timezone)
+		if err != nil {
+			slog.Error("error loading timezone", "tz", bc.Timezone, "err", err)
+			continue
+		}
+		tz := n.timezoneValue(loc.String())
+		now := utcNow.In(loc)
+		start, end := bc.bounds(now)
+
+		ch <- prometheus.MustNewConstMetric(billingCycleDay.desc(n), prometheus.GaugeValue, float64(daysBetween(start, now)+1), bc.Name, tz)
+		ch <- prometheus.MustNewConstMetric(billingCycleLengthDays.desc(n), prometheus.GaugeValue, float64(daysBetween(start, end)), bc.Name, tz)
+		ch <- prometheus.MustNewConstMetric(billingCycleStart.desc(n), prometheus.GaugeValue, float64(start.Unix()), bc.Name, tz)
+		ch <- prometheus.MustNewConstMetric(billingCycleID.desc(n), prometheus.GaugeValue, 1, bc.Name, tz, start.Format(time.DateOnly))
+	}
+}
+
+func describeFiscalCalendars(ch chan<- *prometheus.Desc, c config) {
+	n := c.MetricNaming
+	ch <- fiscalYear.desc(n)
+	ch <- fiscalQuarter.desc(n)
+	ch <- fiscalPeriod.desc(n)
+	ch <- fiscalWeek.desc(n)
+}
+
+func collectFiscalCalendars(ch chan<- prometheus.Metric, c config, utcNow time.Time) {
+	n := c.MetricNaming
+	for _, fc := range c.FiscalCalendars {
+		slog.Debug("Collecting fiscal calendar", "calendar", fc.Name)
+		loc, err := loadLocation(fc.Timezone)
+		if err != nil {
+			slog.Error("error loading timezone", "tz", fc.Timezone, "err", err)
+			continue
+		}
+		tz := n.timezoneValue(loc.String())
+		d := fc.date(utcNow.In(loc))
+
+		ch <- prometheus.MustNewConstMetric(fiscalYear.desc(n), prometheus.GaugeValue, float64(d.year), fc.Name, tz)
+		ch <- prometheus.MustNewConstMetric(fiscalQuarter.desc(n), prometheus.GaugeValue, float64(d.quarter), fc.Name, tz)
+		ch <- prometheus.MustNewConstMetric(fiscalPeriod.desc(n), prometheus.GaugeValue, float64(d.period), fc.Name, tz)
+		ch <- prometheus.MustNewConstMetric(fiscalWeek.desc(n), prometheus.GaugeValue, float64(d.week), fc.Name, tz)
+	}
 }
 
-func describeLocalizedTimezones(ch chan<- *prometheus.Desc) {
-	ch <- minuteLocalized
-	ch <- hourLocalized
-	ch <- dayOfWeekLocalized
-	ch <- dayOfMonthLocalized
-	ch <- monthLocalized
+func describeLocations(ch chan<- *prometheus.Desc, c config) {
+	n := c.MetricNaming
+	ch <- solarSunrise.desc(n)
+	ch <- solarSunset.desc(n)
+	ch <- solarCivilDawn.desc(n)
+	ch <- solarCivilDusk.desc(n)
+	ch <- solarNauticalDawn.desc(n)
+	ch <- solarNauticalDusk.desc(n)
+	ch <- solarNoonTimestamp.desc(n)
+	ch <- solarDaylightDuration.desc(n)
+	ch <- solarElevationDegrees.desc(n)
+	ch <- solarIsDaylight.desc(n)
 }
 
-func collectLocalizedTimezones(ch chan<- prometheus.Metric, utcNow time.Time) {
-	for _, tz := range liveConfig.LocalizedTimezones {
-		slog.Debug("Collecting localized timezone", "tz", tz)
-		loc, err := time.LoadLocation(tz)
+func collectLocations(ch chan<- prometheus.Metric, c config, utcNow time.Time) {
+	n := c.MetricNaming
+	for _, l := range c.Locations {
+		slog.Debug("Collecting location", "location", l.Name)
+		loc, err := loadLocation(l.Timezone)
 		if err != nil {
-			slog.Error("error loading timezone", "tz", tz, "err", err)
+			slog.Error("error loading timezone", "tz", l.Timezone, "err", err)
 			continue
 		}
-		ch <- prometheus.MustNewConstMetric(minuteLocalized, prometheus.GaugeValue, float64(utcNow.In(loc).Minute()), tz)
-		ch <- prometheus.MustNewConstMetric(hourLocalized, prometheus.GaugeValue, float64(utcNow.In(loc).Hour()), tz)
-		ch <- prometheus.MustNewConstMetric(dayOfWeekLocalized, prometheus.GaugeValue, float64(utcNow.In(loc).Weekday()), tz, utcNow.In(loc).Weekday().String())
-		ch <- prometheus.MustNewConstMetric(dayOfMonthLocalized, prometheus.GaugeValue, float64(utcNow.In(loc).Day()), tz)
-		ch <- prometheus.MustNewConstMetric(monthLocalized, prometheus.GaugeValue, float64(utcNow.In(loc).Month()), tz, utcNow.In(loc).Month().String())
+		tz := n.timezoneValue(loc.String())
+		now := utcNow.In(loc)
+
+		events := []struct {
+			desc   *prometheus.Desc
+			zenith float64
+			rising bool
+		}{
+			{solarSunrise.desc(n), sunriseZenith, true},
+			{solarSunset.desc(n), sunriseZenith, false},
+			{solarCivilDawn.desc(n), civilZenith, true},
+			{solarCivilDusk.desc(n), civilZenith, false},
+			{solarNauticalDawn.desc(n), nauticalZenith, true},
+			{solarNauticalDusk.desc(n), nauticalZenith, false},
+		}
+		for _, e := range events {
+			if event := solarEventTime(l.Latitude, l.Longitude, now, e.zenith, e.rising); event.ok() {
+				ch <- prometheus.MustNewConstMetric(e.desc, prometheus.GaugeValue, float64(event.time.Unix()), l.Name, tz)
+			}
+		}
+
+		elevation := solarElevation(l.Latitude, l.Longitude, now)
+		isDaylight := 0.0
+		if elevation > 90-sunriseZenith {
+			isDaylight = 1
+		}
+		ch <- prometheus.MustNewConstMetric(solarNoonTimestamp.desc(n), prometheus.GaugeValue, float64(solarNoon(l.Latitude, l.Longitude, now).Unix()), l.Name, tz)
+		ch <- prometheus.MustNewConstMetric(solarDaylightDuration.desc(n), prometheus.GaugeValue, daylightDuration(l.Latitude, l.Longitude, now).Seconds(), l.Name, tz)
+		ch <- prometheus.MustNewConstMetric(solarElevationDegrees.desc(n), prometheus.GaugeValue, elevation, l.Name, tz)
+		ch <- prometheus.MustNewConstMetric(solarIsDaylight.desc(n), prometheus.GaugeValue, isDaylight, l.Name, tz)
 	}
 }
 
-func describeTOUMetrics(ch chan<- *prometheus.Desc) {
+func describeTOUMetrics(ch chan<- *prometheus.Desc, c config) {
+	n := c.MetricNaming
 	slog.Debug("Describing TOU metrics")
-	for _, tou := range liveConfig.TimeOfUse {
-		loc, err := time.LoadLocation(tou.Timezone)
+	for _, tou := range c.TimeOfUse {
+		loc, err := loadLocation(tou.Timezone)
 		if err != nil {
 			slog.Error("error loading timezone. This should never error as TZ are validated on config load", "err", err, "timezone", tou.Timezone)
 			continue
 		}
-		ch <- describeTOUMetric(tou, time.Now().In(loc))
+		ch <- describeTOUMetric(n, tou, time.Now().In(loc))
+		if tou.WindowActiveMetric {
+			ch <- describeWindowActiveMetric(n, tou)
+		}
+		if tou.IntegralMetrics {
+			integralDesc, windowSecondsDesc := describeIntegralMetrics(n, tou)
+			ch <- integralDesc
+			ch <- windowSecondsDesc
+		}
+		if tou.Source != "" {
+			ch <- feedLastUpdate.desc(n)
+			ch <- feedCoverageEnd.desc(n)
+		}
+		for _, r := range tou.Statistics {
+			descs := describeStatisticsMetrics(n, tou, r)
+			ch <- descs.min
+			ch <- descs.max
+			ch <- descs.mean
+			ch <- descs.currentPercentile
+		}
+		if tou.CheapestBlock != nil {
+			ch <- describeCheapestBlockMetric(n, tou)
+		}
+		if len(tou.DemandWindows) > 0 {
+			descs := describeDemandMetrics(n, tou)
+			ch <- descs.active
+			ch <- descs.rate
+			ch <- descs.periodStart
+			ch <- descs.periodEnd
+		}
 	}
 }
 
-func collectTOUMetrics(ch chan<- prometheus.Metric, utcNow time.Time) {
-	for _, tou := range liveConfig.TimeOfUse {
-		// If tou.Timezone is not set, time.LoadLocation returns UTC
+func collectTOUMetrics(ch chan<- prometheus.Metric, c config, utcNow time.Time) {
+	n := c.MetricNaming
+	for _, tou := range c.TimeOfUse {
+		// If tou.Timezone is not set, loadLocation returns UTC
 		// Which was not known when this was written, but it saves having
 		// to write logic to handle that case.
-		loc, err := time.LoadLocation(tou.Timezone)
+		loc, err := loadLocation(tou.Timezone)
 		if err != nil {
 			slog.Error("error loading timezone. This should never error as TZ are validated on config load", "err", err, "timezone", tou.Timezone)
 			continue
 		}
-		ch <- prometheus.MustNewConstMetric(
-			describeTOUMetric(tou, utcNow.In(loc)),
-			prometheus.GaugeValue,
-			calculateTOUValue(tou, utcNow.In(loc)),
-		)
+		v, ok := currentTOUValue(tou, utcNow.In(loc))
+		if ok {
+			desc := describeTOUMetric(n, tou, utcNow.In(loc))
+			for _, s := range touSeriesValues(tou, utcNow.In(loc), v) {
+				ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, s.value, s.labelValues...)
+			}
+		}
+		if tou.WindowActiveMetric {
+			collectWindowActiveMetric(ch, n, tou, utcNow.In(loc))
+		}
+		if tou.IntegralMetrics {
+			collectIntegralMetrics(ch, n, tou, utcNow.In(loc))
+		}
+		if tou.Source != "" {
+			collectFeedMetrics(ch, n, tou)
+		}
+		if len(tou.Statistics) > 0 {
+			collectStatisticsMetrics(ch, n, tou, utcNow.In(loc), v, ok)
+		}
+		if tou.CheapestBlock != nil {
+			collectCheapestBlockMetric(ch, n, tou, utcNow.In(loc))
+		}
+		if len(tou.DemandWindows) > 0 {
+			collectDemandMetrics(ch, n, tou, utcNow.In(loc))
+		}
+	}
+}
+
+func collectWindowActiveMetric(ch chan<- prometheus.Metric, n metricNaming, tou timeOfUse, now time.Time) {
+	desc := describeWindowActiveMetric(n, tou)
+	active := activeWindowName(tou, now)
+	for _, state := range touWindowStates(tou) {
+		v := 0.0
+		if state == active {
+			v = 1
+		}
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, state)
 	}
 }
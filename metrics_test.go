@@ -17,6 +17,12 @@ var observationCount = map[string]int{
 	"day_of_week":  0,
 	"day_of_month": 0,
 	"month":        0,
+
+	"utc_offset_seconds":                    0,
+	"is_dst":                                0,
+	"zone_info":                             0,
+	"previous_transition_timestamp_seconds": 0,
+	"next_transition_timestamp_seconds":     0,
 }
 
 func verifyMetricDescription(d *prometheus.Desc, t *testing.T) (bool, string) {
@@ -36,7 +42,7 @@ func verifyMetricDescription(d *prometheus.Desc, t *testing.T) (bool, string) {
 
 func TestDescribeLocalizedTimezones(t *testing.T) {
 	testCh := make(chan *prometheus.Desc)
-	go describeLocalizedTimezones(testCh)
+	go describeLocalizedTimezones(testCh, getLiveConfig())
 
 	oc := observationCount
 	for k, _ := range oc {
@@ -63,11 +69,11 @@ func TestDescribeLocalizedTimezones(t *testing.T) {
 
 func TestCollectLocalizedTimezones(t *testing.T) {
 	testCollectCh := make(chan prometheus.Metric)
-	liveConfig = config{LocalizedTimezones: []string{
-		"Pacific/Chatham", // UTC+13:45 - tests minute offsets too
-	}}
+	setLiveConfig(config{LocalizedTimezones: []localizedTimezone{
+		{Timezone: "Pacific/Chatham"}, // UTC+13:45 - tests minute offsets too
+	}})
 	tTime := time.Date(2023, 1, 31, 20, 3, 4, 0, time.UTC)
-	go collectLocalizedTimezones(testCollectCh, tTime)
+	go collectLocalizedTimezones(testCollectCh, getLiveConfig(), tTime)
 
 	oc := observationCount
 	for k, _ := range oc {
@@ -106,6 +112,17 @@ func TestCollectLocalizedTimezones(t *testing.T) {
 			case "month":
 				assert.Equal(t, float64(2), actualValue, "month should be 2 (feb)")
 				assert.Equal(t, "February", labelMap["month"], "day_of_month label should be February")
+			case "utc_offset_seconds":
+				assert.Equal(t, float64(13*60*60+45*60), actualValue, "utc_offset_seconds should be 13h45m")
+			case "is_dst":
+				assert.Equal(t, float64(1), actualValue, "is_dst should be 1 in summer")
+			case "zone_info":
+				assert.Equal(t, float64(1), actualValue, "zone_info should be 1")
+				assert.Equal(t, "+1345", labelMap["abbreviation"], "abbreviation label should be +1345")
+			case "previous_transition_timestamp_seconds":
+				assert.Equal(t, float64(time.Date(2022, 9, 24, 14, 0, 0, 0, time.UTC).Unix()), actualValue, "previous transition should be the start of daylight saving")
+			case "next_transition_timestamp_seconds":
+				assert.Equal(t, float64(time.Date(2023, 4, 1, 14, 0, 0, 0, time.UTC).Unix()), actualValue, "next transition should be the end of daylight saving")
 			}
 		}
 	}
@@ -120,3 +137,257 @@ func TestCollectLocalizedTimezones(t *testing.T) {
 		assert.Equal(t, 1, v, k+" should have been observed exactly once")
 	}
 }
+
+func TestCollectWindowActiveMetric(t *testing.T) {
+	testCh := make(chan prometheus.Metric)
+	tou := timeOfUse{
+		Name:               "test",
+		Labels:             map[string]string{"provider": "Power Co"},
+		WindowActiveMetric: true,
+		TimeWindows: []timeWindow{
+			{Name: "peak", startHour: 7, endHour: 11},
+			{Name: "peak", startHour: 17, endHour: 21},
+		},
+	}
+	go func() {
+		collectWindowActiveMetric(testCh, metricNaming{}, tou, time.Date(2023, 12, 1, 18, 0, 0, 0, time.UTC))
+		close(testCh)
+	}()
+
+	states := map[string]float64{}
+	for m := range testCh {
+		actual := &dto.Metric{}
+		if err := m.Write(actual); err != nil {
+			t.Fatal(err)
+		}
+		labelMap := map[string]string{}
+		for _, l := range actual.GetLabel() {
+			labelMap[l.GetName()] = l.GetValue()
+		}
+		assert.Equal(t, "UTC", labelMap["tz"])
+		assert.Equal(t, "Power Co", labelMap["provider"])
+		states[labelMap["window"]] = actual.GetGauge().GetValue()
+	}
+
+	assert.Equal(t, map[string]float64{"peak": 1, "default": 0}, states)
+}
+
+func TestCollectTOUMetricsAbsentWhenUnmatched(t *testing.T) {
+	defer setLiveConfig(getLiveConfig())
+	setLiveConfig(config{TimeOfUse: []timeOfUse{{
+		Name:                "event",
+		Description:         "event description",
+		AbsentWhenUnmatched: true,
+		TimeWindows: []timeWindow{{
+			Value:     1,
+			startHour: 7,
+			endHour:   9,
+		}},
+	}}})
+
+	collect := func(now time.Time) []prometheus.Metric {
+		testCh := make(chan prometheus.Metric)
+		go func() {
+			collectTOUMetrics(testCh, getLiveConfig(), now)
+			close(testCh)
+		}()
+		metrics := []prometheus.Metric{}
+		for m := range testCh {
+			metrics = append(metrics, m)
+		}
+		return metrics
+	}
+
+	assert.Len(t, collect(time.Date(2023, 12, 1, 8, 0, 0, 0, time.UTC)), 1, "should emit the series within a time window")
+	assert.Len(t, collect(time.Date(2023, 12, 1, 10, 0, 0, 0, time.UTC)), 0, "should not emit the series outside time windows")
+
+	descCh := make(chan *prometheus.Desc)
+	go func() {
+		describeTOUMetrics(descCh, getLiveConfig())
+		close(descCh)
+	}()
+	descs := 0
+	for range descCh {
+		descs++
+	}
+	assert.Equal(t, 1, descs, "should still describe the metric")
+}
+
+func TestCollectBillingCycles(t *testing.T) {
+	defer setLiveConfig(getLiveConfig())
+	setLiveConfig(config{BillingCycles: []billingCycle{{
+		Name:          "power",
+		Timezone:      "Pacific/Auckland",
+		billingPeriod: billingPeriod{StartDay: 15},
+	}}})
+	auckland, err := time.LoadLocation("Pacific/Auckland")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCh := make(chan prometheus.Metric)
+	go func() {
+		// 2024-03-20 08:00 in Auckland
+		collectBillingCycles(testCh, getLiveConfig(), time.Date(2024, 3, 19, 19, 0, 0, 0, time.UTC))
+		close(testCh)
+	}()
+
+	values := map[string]float64{}
+	for m := range testCh {
+		actual := &dto.Metric{}
+		if err := m.Write(actual); err != nil {
+			t.Fatal(err)
+		}
+		labelMap := map[string]string{}
+		for _, l := range actual.GetLabel() {
+			labelMap[l.GetName()] = l.GetValue()
+		}
+		assert.Equal(t, "power", labelMap["cycle"])
+		assert.Equal(t, "Pacific/Auckland", labelMap["tz"])
+
+		_, after, _ := strings.Cut(m.Desc().String(), "tou_exporter_billing_cycle_")
+		n := strings.Split(after, `"`)[0]
+		if n == "id" {
+			assert.Equal(t, "2024-03-15", labelMap["id"])
+		}
+		values[n] = actual.GetGauge().GetValue()
+	}
+
+	assert.Equal(t, map[string]float64{
+		"day":                     6,
+		"length_days":             31,
+		"start_timestamp_seconds": float64(time.Date(2024, 3, 15, 0, 0, 0, 0, auckland).Unix()),
+		"id":                      1,
+	}, values)
+}
+
+func TestCollectLocalizedTimezonesFamilies(t *testing.T) {
+	defer setLiveConfig(getLiveConfig())
+	families := []string{"year", "day_of_year", "iso_week", "iso_year", "quarter", "week_of_month",
+		"days_in_month", "minute_of_day", "seconds_since_midnight", "is_weekend"}
+	setLiveConfig(config{LocalizedTimezones: []localizedTimezone{
+		{Timezone: "Pacific/Chatham", Metrics: families},
+	}})
+
+	tests := []struct {
+		now      time.Time
+		expected map[string]float64
+	}{
+		{
+			// Wednesday 2023-02-01 09:48:04 in Chatham
+			now: time.Date(2023, 1, 31, 20, 3, 4, 0, time.UTC),
+			expected: map[string]float64{
+				"year":                   2023,
+				"day_of_year":            32,
+				"iso_week":               5,
+				"iso_year":               2023,
+				"quarter":                1,
+				"week_of_month":          1,
+				"days_in_month":          28,
+				"minute_of_day":          588,
+				"seconds_since_midnight": 35284,
+				"is_weekend":             0,
+			},
+		},
+		{
+			// Sunday 2023-01-01 in Chatham is in the last ISO week of 2022
+			now: time.Date(2022, 12, 31, 20, 0, 0, 0, time.UTC),
+			expected: map[string]float64{
+				"year":          2023,
+				"iso_week":      52,
+				"iso_year":      2022,
+				"week_of_month": 1,
+				"is_weekend":    1,
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		testCh := make(chan prometheus.Metric)
+		go func() {
+			collectLocalizedTimezones(testCh, getLiveConfig(), tc.now)
+			close(testCh)
+		}()
+
+		values := map[string]float64{}
+		for m := range testCh {
+			actual := &dto.Metric{}
+			if err := m.Write(actual); err != nil {
+				t.Fatal(err)
+			}
+			_, after, _ := strings.Cut(m.Desc().String(), "tou_exporter_localized_")
+			values[strings.Split(after, `"`)[0]] = actual.GetGauge().GetValue()
+		}
+
+		assert.Len(t, values, len(families), "only the configured families should be collected")
+		for k, v := range tc.expected {
+			assert.Equal(t, v, values[k], k)
+		}
+	}
+}
+
+func TestLocalizedSecondsSinceMidnight(t *testing.T) {
+	auckland, err := time.LoadLocation("Pacific/Auckland")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Daylight saving starts at 02:00
+	now := time.Date(2023, 9, 24, 4, 0, 0, 0, auckland)
+	for _, f := range localizedFamilies {
+		v, _ := f.value(now)
+		switch f.name {
+		case "seconds_since_midnight":
+			assert.Equal(t, float64(3*60*60), v)
+		case "minute_of_day":
+			assert.Equal(t, float64(4*60), v)
+		}
+	}
+}
+
+func TestDescribeLocalizedTimezonesFamilies(t *testing.T) {
+	defer setLiveConfig(getLiveConfig())
+	setLiveConfig(config{LocalizedTimezones: []localizedTimezone{
+		{Timezone: "Pacific/Auckland"},
+		{Timezone: "Pacific/Chatham", Metrics: []string{"hour", "is_weekend"}},
+	}})
+
+	testCh := make(chan *prometheus.Desc)
+	go func() {
+		describeLocalizedTimezones(testCh, getLiveConfig())
+		close(testCh)
+	}()
+
+	descs := []string{}
+	for d := range testCh {
+		_, after, _ := strings.Cut(d.String(), "tou_exporter_localized_")
+		descs = append(descs, strings.Split(after, `"`)[0])
+	}
+	assert.Equal(t, []string{"minute", "hour", "day_of_week", "day_of_month", "month", "utc_offset_seconds", "is_dst",
+		"zone_info", "previous_transition_timestamp_seconds", "next_transition_timestamp_seconds", "is_weekend"}, descs)
+}
+
+func TestCollectLocalizedTimezonesWithoutTransitions(t *testing.T) {
+	defer setLiveConfig(getLiveConfig())
+	setLiveConfig(config{LocalizedTimezones: []localizedTimezone{{Timezone: "UTC"}}})
+
+	testCh := make(chan prometheus.Metric)
+	go func() {
+		collectLocalizedTimezones(testCh, getLiveConfig(), time.Date(2023, 1, 31, 20, 3, 4, 0, time.UTC))
+		close(testCh)
+	}()
+
+	values := map[string]float64{}
+	for m := range testCh {
+		actual := &dto.Metric{}
+		if err := m.Write(actual); err != nil {
+			t.Fatal(err)
+		}
+		_, after, _ := strings.Cut(m.Desc().String(), "tou_exporter_localized_")
+		values[strings.Split(after, `"`)[0]] = actual.GetGauge().GetValue()
+	}
+
+	assert.Equal(t, 0.0, values["utc_offset_seconds"])
+	assert.Equal(t, 0.0, values["is_dst"])
+	assert.NotContains(t, values, "previous_transition_timestamp_seconds", "UTC has no transitions")
+	assert.NotContains(t, values, "next_transition_timestamp_seconds", "UTC has no transitions")
+}
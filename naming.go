@@ -0,0 +1,112 @@
+package main
+
+import (
+	"regexp"
+	"slices"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	defaultNamespace     = "tou_exporter"
+	defaultTimezoneLabel = "tz"
+)
+
+var (
+	metricNameRegexp = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	labelNameRegexp  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+func (n metricNaming) namespace() string {
+	if n.Namespace == "" {
+		return defaultNamespace
+	}
+	return n.Namespace
+}
+
+func (n metricNaming) timezoneLabel() string {
+	if n.TimezoneLabel == "" {
+		return defaultTimezoneLabel
+	}
+	return n.TimezoneLabel
+}
+
+// timezoneValue returns the value of the timezone label for a timezone, which
+// is its alias if it has one.
+func (n metricNaming) timezoneValue(tz string) string {
+	if alias, ok := n.TimezoneAliases[tz]; ok {
+		return alias
+	}
+	return tz
+}
+
+// dropsLabel returns whether a localized metric label is dropped.
+func (n metricNaming) dropsLabel(label string) bool {
+	return label == "day" && n.DropDayLabel || label == "month" && n.DropMonthLabel
+}
+
+// reservedLabels returns the labels the exporter's metrics have besides the
+// timezone label, which the timezone label can't be renamed to.
+func reservedLabels() []string {
+	labels := slices.Concat(touTierLabels, []string{touWindowLabel, fixedChargePeriodLabel})
+	for _, m := range exporterMetrics {
+		for _, l := range m.labels {
+			if l != defaultTimezoneLabel {
+				labels = append(labels, l)
+			}
+		}
+	}
+	for _, f := range localizedFamilies {
+		labels = append(labels, f.labels...)
+	}
+	return labels
+}
+
+// exporterMetric is a metric of the exporter itself, named in the configured
+// namespace. A defaultTimezoneLabel in labels is renamed to the configured
+// timezone label.
+type exporterMetric struct {
+	name   string
+	help   string
+	labels []string
+}
+
+func (m exporterMetric) desc(n metricNaming) *prometheus.Desc {
+	labels := make([]string, len(m.labels))
+	for i, l := range m.labels {
+		if l == defaultTimezoneLabel {
+			l = n.timezoneLabel()
+		}
+		labels[i] = l
+	}
+	return prometheus.NewDesc(n.namespace()+"_"+m.name, m.help, labels, nil)
+}
+
+// configuredMetricName returns the name of a metric named in the config, such
+// as a time of use or fixed charge.
+func (n metricNaming) configuredMetricName(name string) string {
+	if n.PrefixTimeOfUse {
+		return n.namespace() + "_" + name
+	}
+	return name
+}
+
+// touMetricName returns the name of the metric of a time of use, which
+// prefixes the names of all its other metrics.
+func (n metricNaming) touMetricName(tou timeOfUse) string {
+	return n.configuredMetricName(tou.Name)
+}
+
+// timezoneLabels returns the timezone label of series in a timezone, which is
+// UTC if unset.
+func (n metricNaming) timezoneLabels(tz string) map[string]string {
+	if tz == "" {
+		tz = "UTC"
+	}
+	return map[string]string{n.timezoneLabel(): n.timezoneValue(tz)}
+}
+
+// touTimezoneLabels returns the timezone label of a time of use.
+func (n metricNaming) touTimezoneLabels(tou timeOfUse) map[string]string {
+	return n.timezoneLabels(tou.Timezone)
+}
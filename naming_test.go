@@ -0,0 +1,235 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+var namingTestConfig = metricNaming{
+	Namespace:       "site",
+	PrefixTimeOfUse: true,
+	TimezoneLabel:   "region",
+	TimezoneAliases: map[string]string{"Pacific/Auckland": "nz"},
+	DropDayLabel:    true,
+}
+
+func TestCollectLocalizedTimezonesNaming(t *testing.T) {
+	defer setLiveConfig(getLiveConfig())
+	setLiveConfig(config{
+		LocalizedTimezones: []localizedTimezone{{Timezone: "Pacific/Auckland", Metrics: []string{"day_of_week", "month"}}},
+		MetricNaming:       namingTestConfig,
+	})
+
+	testCh := make(chan prometheus.Metric)
+	go func() {
+		collectLocalizedTimezones(testCh, getLiveConfig(), time.Date(2023, 1, 31, 20, 3, 4, 0, time.UTC))
+		close(testCh)
+	}()
+
+	labels := map[string]map[string]string{}
+	for m := range testCh {
+		actual := &dto.Metric{}
+		if err := m.Write(actual); err != nil {
+			t.Fatal(err)
+		}
+		name := strings.Split(m.Desc().String(), `"`)[1]
+		labels[name] = map[string]string{}
+		for _, l := range actual.GetLabel() {
+			labels[name][l.GetName()] = l.GetValue()
+		}
+	}
+
+	assert.Equal(t, map[string]map[string]string{
+		"site_localized_day_of_week": {"region": "nz"},
+		"site_localized_month":       {"region": "nz", "month": "February"},
+	}, labels)
+}
+
+func TestDescribeTOUMetricNaming(t *testing.T) {
+	tou := timeOfUse{Name: "electricity_price", Timezone: "Pacific/Auckland", Labels: map[string]string{"provider": "Power Co"}}
+	desc := describeTOUMetric(namingTestConfig, tou, time.Date(2023, 12, 1, 12, 0, 0, 0, time.UTC)).String()
+	assert.Contains(t, desc, `fqName: "site_electricity_price"`)
+	assert.Contains(t, desc, `region="nz"`)
+	assert.NotContains(t, desc, "tz=")
+
+	assert.Contains(t, describeWindowActiveMetric(namingTestConfig, tou).String(), `fqName: "site_electricity_price_window_active"`)
+	assert.Equal(t, map[string]string{"region": "nz", "provider": "Power Co"}, namingTestConfig.touConstLabels(tou))
+}
+
+func TestValidateMetricNaming(t *testing.T) {
+	assert.NoError(t, validateMetricNaming(metricNaming{}))
+	assert.NoError(t, validateMetricNaming(namingTestConfig))
+	assert.Equal(t, errors.New(`Invalid metric namespace. Must match ^[a-zA-Z_:][a-zA-Z0-9_:]*$. Got: "my-site"`),
+		validateMetricNaming(metricNaming{Namespace: "my-site"}))
+	assert.Equal(t, errors.New(`Invalid timezone label. Must match ^[a-zA-Z_][a-zA-Z0-9_]*$. Got: "time zone"`),
+		validateMetricNaming(metricNaming{TimezoneLabel: "time zone"}))
+	for _, l := range []string{"day", "month", "abbreviation", "cycle", "id", "calendar", "location", "name", "source", "period", "window", "tier", "tier_upper_bound"} {
+		assert.Equal(t, fmt.Errorf(`Timezone label "%s" clashes with a label of the exporter's metrics`, l),
+			validateMetricNaming(metricNaming{TimezoneLabel: l}), l)
+	}
+}
+
+func TestLoadConfigMetricNaming(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "config_test.*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(f.Name(), []byte(`
+metric_naming:
+  timezone_label: region
+time_of_use:
+- name: naming_test
+  variable_labels: [region]
+`), 0644)
+
+	_, err = loadConfig(f.Name())
+	assert.Equal(t, errors.New(`"region" is reserved and can not be used as a variable label`), err)
+
+	os.WriteFile(f.Name(), []byte(`
+metric_naming:
+  timezone_label: region
+time_of_use:
+- name: naming_test
+  variable_labels: [tz]
+`), 0644)
+	_, err = loadConfig(f.Name())
+	assert.NoError(t, err, "tz is only reserved while it is the timezone label")
+
+	os.WriteFile(f.Name(), []byte(`
+time_of_use:
+- name: naming_test
+  variable_labels: [tz]
+`), 0644)
+	_, err = loadConfig(f.Name())
+	assert.Equal(t, errors.New(`"tz" is reserved and can not be used as a variable label`), err)
+
+	os.WriteFile(f.Name(), []byte(`
+metric_naming:
+  timezone_label: location
+locations:
+- name: london
+  latitude: 51.5
+  longitude: -0.13
+`), 0644)
+	_, err = loadConfig(f.Name())
+	assert.Equal(t, errors.New(`Timezone label "location" clashes with a label of the exporter's metrics`), err)
+
+	os.WriteFile(f.Name(), []byte(`
+metric_naming:
+  timezone_label: region
+time_of_use:
+- name: naming_test
+  labels:
+    region: north
+`), 0644)
+	_, err = loadConfig(f.Name())
+	assert.Equal(t, errors.New(`"region" is reserved and can not be used as a label`), err)
+
+	os.WriteFile(f.Name(), []byte(`
+metric_naming:
+  timezone_label: region
+time_of_use:
+- name: naming_test
+  time_windows:
+  - value: 1
+    start: '07:00'
+    end: '09:00'
+    labels:
+      region: north
+`), 0644)
+	_, err = loadConfig(f.Name())
+	assert.Equal(t, errors.New(`"region" is reserved and can not be used as a label`), err)
+
+	os.WriteFile(f.Name(), []byte(`
+metric_naming:
+  timezone_label: region
+fixed_charges:
+- name: supply_charge
+  period: day
+  labels:
+    region: north
+`), 0644)
+	_, err = loadConfig(f.Name())
+	assert.Equal(t, errors.New(`"region" is reserved and can not be used as a label`), err)
+}
+
+func TestCollectExporterMetricsNaming(t *testing.T) {
+	defer setLiveConfig(getLiveConfig())
+	setLiveConfig(config{
+		BillingCycles:   []billingCycle{{Name: "monthly", Timezone: "Pacific/Auckland", billingPeriod: billingPeriod{StartDay: 1}}},
+		FiscalCalendars: []fiscalCalendar{{Name: "april", Timezone: "Pacific/Auckland", StartMonth: 4, StartDay: 1}},
+		Locations:       []location{{Name: "wellington", Latitude: -41.29, Longitude: 174.78, Timezone: "Pacific/Auckland"}},
+		MetricNaming:    namingTestConfig,
+	})
+
+	testCh := make(chan prometheus.Metric)
+	go func() {
+		now := time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC)
+		collectBillingCycles(testCh, getLiveConfig(), now)
+		collectFiscalCalendars(testCh, getLiveConfig(), now)
+		collectLocations(testCh, getLiveConfig(), now)
+		close(testCh)
+	}()
+
+	for m := range testCh {
+		actual := &dto.Metric{}
+		if err := m.Write(actual); err != nil {
+			t.Fatal(err)
+		}
+		name := strings.Split(m.Desc().String(), `"`)[1]
+		assert.True(t, strings.HasPrefix(name, "site_"), name)
+		labels := map[string]string{}
+		for _, l := range actual.GetLabel() {
+			labels[l.GetName()] = l.GetValue()
+		}
+		assert.Equal(t, "nz", labels["region"], name)
+		assert.NotContains(t, labels, "tz", name)
+	}
+
+	assert.Contains(t, feedLastUpdate.desc(namingTestConfig).String(), `fqName: "site_feed_last_update_timestamp_seconds"`)
+}
+
+func TestCollectTOUMetricsUsesConfigSnapshot(t *testing.T) {
+	defer setLiveConfig(getLiveConfig())
+	setLiveConfig(config{})
+
+	c := config{
+		TimeOfUse:    []timeOfUse{{Name: "electricity_price", Timezone: "Pacific/Auckland", DefaultValue: 0.2, WindowActiveMetric: true}},
+		MetricNaming: namingTestConfig,
+	}
+	testCh := make(chan prometheus.Metric)
+	go func() {
+		collectTOUMetrics(testCh, c, time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC))
+		close(testCh)
+	}()
+
+	names := []string{}
+	for m := range testCh {
+		desc := m.Desc().String()
+		assert.Contains(t, desc, `region="nz"`)
+		names = append(names, strings.Split(desc, `"`)[1])
+	}
+	assert.Equal(t, []string{"site_electricity_price", "site_electricity_price_window_active"}, names)
+}
+
+func TestDescribeFixedChargeNaming(t *testing.T) {
+	fc := fixedCharge{Name: "supply_charge", Description: "Daily supply charge", Timezone: "Pacific/Auckland", Period: "day"}
+	amount, rate, accrued := describeFixedCharge(namingTestConfig, fc)
+	assert.Contains(t, amount.String(), `fqName: "site_supply_charge"`)
+	assert.Contains(t, rate.String(), `fqName: "site_supply_charge_rate_per_second"`)
+	assert.Contains(t, accrued.String(), `fqName: "site_supply_charge_accrued_total"`)
+	assert.Contains(t, amount.String(), `region="nz"`)
+	assert.NotContains(t, amount.String(), "tz=")
+
+	amount, _, _ = describeFixedCharge(metricNaming{}, fixedCharge{Name: "supply_charge", Period: "day"})
+	assert.Contains(t, amount.String(), `fqName: "supply_charge"`)
+	assert.Contains(t, amount.String(), `tz="UTC"`)
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	defaultPlanSlot = 30 * time.Minute
+	// Limits in slots on the size of a plan, which bound the memory and time
+	// used by cheapestSlots
+	maxPlanSlots       = 7 * 24 * 12
+	maxPlanRunSlots    = 24 * 12
+	maxPlanMinRunSlots = 4 * 12
+)
+
+// planRequest describes a flexible load to schedule against a time of use.
+type planRequest struct {
+	// Total time to run for, rounded up to whole slots
+	Runtime time.Duration
+	// Granularity of the plan. Slots are aligned to midnight
+	Slot time.Duration
+	// Shortest run of contiguous slots. Defaults to one slot
+	MinRun time.Duration
+	// Power drawn while running, multiplied with the value per hour for cost
+	Power     float64
+	NotBefore time.Time
+	Deadline  time.Time
+}
+
+type planSlot struct {
+	Start        time.Time `json:"start"`
+	End          time.Time `json:"end"`
+	AverageValue float64   `json:"average_value"`
+}
+
+// plan is the cheapest set of slots to run a load in.
+type plan struct {
+	Name    string     `json:"name"`
+	Slots   []planSlot `json:"slots"`
+	Runtime string     `json:"runtime"`
+	Cost    float64    `json:"cost"`
+}
+
+// planSlots splits the time between not before and the deadline into slots
+// aligned to midnight, in the location of not before.
+func planSlots(tou timeOfUse, req planRequest) []planSlot {
+	nb := req.NotBefore
+	midnight := time.Date(nb.Year(), nb.Month(), nb.Day(), 0, 0, 0, 0, nb.Location())
+	start := midnight.Add((nb.Sub(midnight) + req.Slot - 1) / req.Slot * req.Slot)
+
+	slots := []planSlot{}
+	for ; !start.Add(req.Slot).After(req.Deadline); start = start.Add(req.Slot) {
+		end := start.Add(req.Slot)
+		segments := touSegments(tou, start, end)
+		slots = append(slots, planSlot{
+			Start:        start,
+			End:          end,
+			AverageValue: segmentsIntegral(segments, start, end) / req.Slot.Seconds(),
+		})
+	}
+	return slots
+}
+
+// withDefaults returns the request with unset options defaulted.
+func (req planRequest) withDefaults() planRequest {
+	if req.Slot == 0 {
+		req.Slot = defaultPlanSlot
+	}
+	if req.MinRun == 0 {
+		req.MinRun = req.Slot
+	}
+	if req.Power == 0 {
+		req.Power = 1
+	}
+	return req
+}
+
+// validatePlanRequest ensures a plan request with defaults is small enough to
+// plan.
+func validatePlanRequest(req planRequest) error {
+	if req.Runtime <= 0 || req.Slot <= 0 || req.MinRun < 0 {
+		return errors.New("Runtime and slot must be positive, and min run can not be negative")
+	}
+	if slots := req.Deadline.Sub(req.NotBefore) / req.Slot; slots > maxPlanSlots {
+		return fmt.Errorf("Plans can have at most %d slots before the deadline. Got: %d slots of %s", maxPlanSlots, slots, req.Slot)
+	}
+	if slots := ceilSlots(req.Runtime, req.Slot); slots > maxPlanRunSlots {
+		return fmt.Errorf("Runtime can be at most %d slots. Got: %d slots of %s", maxPlanRunSlots, slots, req.Slot)
+	}
+	if slots := ceilSlots(req.MinRun, req.Slot); slots > maxPlanMinRunSlots {
+		return fmt.Errorf("Min run can be at most %d slots. Got: %d slots of %s", maxPlanMinRunSlots, slots, req.Slot)
+	}
+	return nil
+}
+
+// ceilSlots returns the number of slots needed to cover d.
+func ceilSlots(d, slot time.Duration) int {
+	return int((d + slot - 1) / slot)
+}
+
+// planLoad finds the cheapest, possibly non-contiguous, set of slots to run a
+// load in before the deadline, where every run of contiguous slots is at
+// least the min run long. Ties go to the earliest slots. NotBefore should be
+// in the timezone of the time of use.
+func planLoad(tou timeOfUse, req planRequest) (plan, error) {
+	req = req.withDefaults()
+	if err := validatePlanRequest(req); err != nil {
+		return plan{}, err
+	}
+
+	slots := planSlots(tou, req)
+	need := ceilSlots(req.Runtime, req.Slot)
+	minRun := ceilSlots(req.MinRun, req.Slot)
+	if need > len(slots) {
+		return plan{}, fmt.Errorf("Runtime of %s doesn't fit in the %d slots before the deadline", req.Runtime, len(slots))
+	}
+	if minRun > need {
+		return plan{}, fmt.Errorf("Min run of %s is longer than the runtime of %s", req.MinRun, req.Runtime)
+	}
+
+	chosen, ok := cheapestSlots(slots, need, minRun)
+	if !ok {
+		return plan{}, errors.New("No plan has runs of at least the min run before the deadline")
+	}
+
+	p := plan{Name: tou.Name, Runtime: (time.Duration(need) * req.Slot).String(), Slots: []planSlot{}}
+	for i, c := range chosen {
+		if c {
+			p.Slots = append(p.Slots, slots[i])
+			p.Cost += slots[i].AverageValue * req.Power * req.Slot.Hours()
+		}
+	}
+	return p, nil
+}
+
+// cheapestSlots chooses exactly need slots with the lowest total value, where
+// every run of chosen slots is at least minRun long. It's a dynamic programme
+// working back from the last slot, over the number of slots still needed and
+// the length of the current run, capped at minRun. Only the costs of the next
+// slot are kept, with a bit per state recording whether to run.
+func cheapestSlots(slots []planSlot, need, minRun int) ([]bool, bool) {
+	n := len(slots)
+	states := (need + 1) * (minRun + 1)
+	state := func(j, r int) int { return j*(minRun+1) + r }
+
+	// next[state(j, r)] is the cheapest cost of slots i+1 onwards, needing j
+	// more slots, having run the r slots before i+1
+	next, cost := make([]float64, states), make([]float64, states)
+	for k := range next {
+		next[k] = math.Inf(1)
+	}
+	next[state(0, 0)], next[state(0, minRun)] = 0, 0
+
+	// run has a bit per slot and state, set when running is the cheapest
+	// choice. Ties run, so the earliest slots are preferred
+	run := make([]uint64, (n*states+63)/64)
+	for i := n - 1; i >= 0; i-- {
+		for j := 0; j <= need; j++ {
+			for r := 0; r <= minRun; r++ {
+				best := math.Inf(1)
+				if r == 0 || r == minRun {
+					best = next[state(j, 0)]
+				}
+				if j > 0 {
+					if c := slots[i].AverageValue + next[state(j-1, min(r+1, minRun))]; c <= best+1e-12 {
+						bit := i*states + state(j, r)
+						run[bit/64] |= 1 << (bit % 64)
+						best = min(best, c)
+					}
+				}
+				cost[state(j, r)] = best
+			}
+		}
+		next, cost = cost, next
+	}
+	if math.IsInf(next[state(need, 0)], 1) {
+		return nil, false
+	}
+
+	chosen := make([]bool, n)
+	j, r := need, 0
+	for i := 0; i < n; i++ {
+		if bit := i*states + state(j, r); run[bit/64]&(1<<(bit%64)) != 0 {
+			chosen[i] = true
+			j, r = j-1, min(r+1, minRun)
+		} else {
+			r = 0
+		}
+	}
+	return chosen, true
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var plannerTestTOU = timeOfUse{
+	Name:         "planner_test",
+	DefaultValue: 0.2,
+	TimeWindows: []timeWindow{
+		{Value: 0.1, startHour: 1, endHour: 2},
+		{Value: 0.05, startHour: 3, endHour: 4},
+		{Value: 0.1, startHour: 5, endHour: 6},
+	},
+}
+
+func planStarts(p plan) []int {
+	hours := []int{}
+	for _, s := range p.Slots {
+		hours = append(hours, s.Start.Hour())
+	}
+	return hours
+}
+
+func TestPlanLoad(t *testing.T) {
+	req := planRequest{
+		Runtime:   3 * time.Hour,
+		Slot:      time.Hour,
+		Power:     2,
+		NotBefore: time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC),
+		Deadline:  time.Date(2023, 12, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	p, err := planLoad(plannerTestTOU, req)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []int{1, 3, 5}, planStarts(p), "should choose non-contiguous slots")
+		assert.InDelta(t, 0.5, p.Cost, 1e-12)
+		assert.Equal(t, "3h0m0s", p.Runtime)
+	}
+
+	req.MinRun = 2 * time.Hour
+	p, err = planLoad(plannerTestTOU, req)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []int{1, 2, 3}, planStarts(p), "should choose the earliest of equally cheap runs")
+		assert.InDelta(t, 0.7, p.Cost, 1e-12)
+	}
+
+	req.MinRun = 0
+	req.Runtime = 150 * time.Minute
+	req.NotBefore = time.Date(2023, 12, 1, 0, 20, 0, 0, time.UTC)
+	p, err = planLoad(plannerTestTOU, req)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []int{1, 3, 5}, planStarts(p), "should round up the runtime, and align slots")
+		assert.Equal(t, 0, p.Slots[0].Start.Minute())
+	}
+
+	req.Deadline = time.Date(2023, 12, 1, 3, 0, 0, 0, time.UTC)
+	_, err = planLoad(plannerTestTOU, req)
+	assert.EqualError(t, err, "Runtime of 2h30m0s doesn't fit in the 2 slots before the deadline")
+
+	req.Deadline = time.Date(2023, 12, 1, 12, 0, 0, 0, time.UTC)
+	req.MinRun = 4 * time.Hour
+	_, err = planLoad(plannerTestTOU, req)
+	assert.EqualError(t, err, "Min run of 4h0m0s is longer than the runtime of 2h30m0s")
+}
+
+func TestValidatePlanRequest(t *testing.T) {
+	nb := time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC)
+	req := planRequest{Runtime: 24 * time.Hour, Slot: 5 * time.Minute, MinRun: 4 * time.Hour, NotBefore: nb, Deadline: nb.Add(7 * 24 * time.Hour)}
+	assert.NoError(t, validatePlanRequest(req))
+
+	req.Slot = time.Minute
+	assert.EqualError(t, validatePlanRequest(req), "Plans can have at most 2016 slots before the deadline. Got: 10080 slots of 1m0s")
+	req.Slot = 5 * time.Minute
+	req.Runtime = 25 * time.Hour
+	assert.EqualError(t, validatePlanRequest(req), "Runtime can be at most 288 slots. Got: 300 slots of 5m0s")
+	req.Runtime = 3 * time.Hour
+	req.MinRun = 5 * time.Hour
+	assert.EqualError(t, validatePlanRequest(req), "Min run can be at most 48 slots. Got: 60 slots of 5m0s")
+}
+
+func TestPlanLoadLimits(t *testing.T) {
+	nb := time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC)
+	p, err := planLoad(plannerTestTOU, planRequest{Runtime: 24 * time.Hour, Slot: 5 * time.Minute, MinRun: 4 * time.Hour, NotBefore: nb, Deadline: nb.Add(7 * 24 * time.Hour)})
+	if assert.NoError(t, err) {
+		assert.Len(t, p.Slots, 288)
+	}
+}
+
+func TestCheapestSlots(t *testing.T) {
+	slots := []planSlot{{AverageValue: 1}, {AverageValue: 5}, {AverageValue: 1}, {AverageValue: 1}, {AverageValue: 5}}
+
+	chosen, ok := cheapestSlots(slots, 2, 1)
+	assert.True(t, ok)
+	assert.Equal(t, []bool{true, false, true, false, false}, chosen)
+
+	chosen, ok = cheapestSlots(slots, 2, 2)
+	assert.True(t, ok)
+	assert.Equal(t, []bool{false, false, true, true, false}, chosen)
+
+	// A single run of 3 slots satisfies a min run of 2
+	chosen, ok = cheapestSlots(slots[:4], 3, 2)
+	assert.True(t, ok)
+	assert.Equal(t, []bool{true, true, true, false}, chosen)
+	_, ok = cheapestSlots([]planSlot{{}, {}, {}}, 2, 3)
+	assert.False(t, ok, "runs can't be shorter than the min run")
+}
+
+func TestPlanCommand(t *testing.T) {
+	defer setLiveConfig(getLiveConfig())
+	f, err := os.CreateTemp(t.TempDir(), "config_test.*.yaml")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(f.Name(), []byte(`
+time_of_use:
+- name: planner_test
+  default_value: 0.2
+  time_windows:
+  - value: 0.05
+    start: '03:00'
+    end: '04:00'
+`), 0644))
+
+	var out bytes.Buffer
+	code := runCommand([]string{"plan", "-config", f.Name(), "-name", "planner_test", "-runtime", "1h", "-slot", "1h",
+		"-not-before", "2023-12-01T00:00:00Z", "-deadline", "2023-12-01T12:00:00Z"}, &out)
+	require.Equal(t, 0, code)
+
+	var p plan
+	require.NoError(t, json.Unmarshal(out.Bytes(), &p))
+	assert.Len(t, p.Slots, 1)
+	assert.True(t, time.Date(2023, 12, 1, 3, 0, 0, 0, time.UTC).Equal(p.Slots[0].Start))
+	assert.InDelta(t, 0.05, p.Cost, 1e-12)
+
+	assert.Equal(t, 2, runCommand([]string{"plan", "-config", f.Name()}, &out), "should require a name and runtime")
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+)
+
+// profileTimeWindows converts a weekly profile grid into time windows.
+// Consecutive slots with the same value and label are merged into a single
+// window, and identical windows on different days share a window. Windows are
+// named by their label, or by their start time when unlabelled.
+func profileTimeWindows(p profile) ([]timeWindow, error) {
+	if p.SlotSize <= 0 || p.SlotSize%time.Minute != 0 || (24*time.Hour)%p.SlotSize != 0 {
+		return nil, fmt.Errorf(`Invalid profile slot size. Must be a whole number of minutes that evenly divides 24h. Got: "%s"`, p.SlotSize)
+	}
+	if len(p.Days) != 7 {
+		return nil, fmt.Errorf("Profile must have 7 days, starting with Sunday. Got: %d", len(p.Days))
+	}
+
+	slots := int(24 * time.Hour / p.SlotSize)
+	slotMinutes := int(p.SlotSize / time.Minute)
+	windows := []timeWindow{}
+	for day, row := range p.Days {
+		if len(row.Values) != slots {
+			return nil, fmt.Errorf("Profile day %d must have %d values for a slot size of %s. Got: %d", day, slots, p.SlotSize, len(row.Values))
+		}
+		if len(row.Labels) > 0 && len(row.Labels) != slots {
+			return nil, fmt.Errorf("Profile day %d must have %d labels for a slot size of %s. Got: %d", day, slots, p.SlotSize, len(row.Labels))
+		}
+		if len(row.Labels) > 0 && p.LabelName == "" {
+			return nil, fmt.Errorf("Profile day %d has labels, but the profile has no label_name", day)
+		}
+
+		for start := 0; start < slots; {
+			end := start + 1
+			for end < slots && row.Values[end] == row.Values[start] && profileSlotLabel(row, end) == profileSlotLabel(row, start) {
+				end++
+			}
+
+			tw := timeWindow{
+				Name:  profileSlotName(start * slotMinutes),
+				Value: row.Values[start],
+				Start: formatWindowTime(start * slotMinutes),
+				End:   formatWindowTime(end * slotMinutes),
+				Days:  []int{day},
+			}
+			if label := profileSlotLabel(row, start); label != "" {
+				tw.Name = label
+				tw.Labels = map[string]string{p.LabelName: label}
+			}
+			windows = mergeProfileWindow(windows, tw)
+			start = end
+		}
+	}
+	return windows, nil
+}
+
+// profileSlotName names a window without a label by its start time, such as
+// slot_0630, so window metrics can be enabled for unlabelled profiles.
+func profileSlotName(minutes int) string {
+	return fmt.Sprintf("slot_%02d%02d", minutes/60, minutes%60)
+}
+
+func profileSlotLabel(row profileDay, slot int) string {
+	if len(row.Labels) == 0 {
+		return ""
+	}
+	return row.Labels[slot]
+}
+
+// mergeProfileWindow adds the window's days to an identical existing window,
+// or appends it when there is none.
+func mergeProfileWindow(windows []timeWindow, tw timeWindow) []timeWindow {
+	for i, w := range windows {
+		if w.Start == tw.Start && w.End == tw.End && w.Value == tw.Value && maps.Equal(w.Labels, tw.Labels) {
+			windows[i].Days = slices.Concat(w.Days, tw.Days)
+			return windows
+		}
+	}
+	return append(windows, tw)
+}
+
+// formatWindowTime formats minutes since midnight as hh:mm, with the end of
+// the day as 00:00.
+func formatWindowTime(minutes int) string {
+	minutes %= 24 * 60
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
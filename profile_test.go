@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfileTimeWindows(t *testing.T) {
+	weekday := profileDay{
+		Values: []float64{0.1, 0.3, 0.2, 0.2},
+		Labels: []string{"night", "peak", "day", "day"},
+	}
+	weekend := profileDay{
+		Values: []float64{0.1, 0.2, 0.2, 0.2},
+		Labels: []string{"night", "day", "day", "day"},
+	}
+
+	windows, err := profileTimeWindows(profile{
+		SlotSize:  6 * time.Hour,
+		LabelName: "rate",
+		Days:      []profileDay{weekend, weekday, weekday, weekday, weekday, weekday, weekend},
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []timeWindow{
+			{
+				Name: "night", Value: 0.1, Start: "00:00", End: "06:00",
+				Days: []int{0, 1, 2, 3, 4, 5, 6}, Labels: map[string]string{"rate": "night"},
+			},
+			{
+				Name: "day", Value: 0.2, Start: "06:00", End: "00:00",
+				Days: []int{0, 6}, Labels: map[string]string{"rate": "day"},
+			},
+			{
+				Name: "peak", Value: 0.3, Start: "06:00", End: "12:00",
+				Days: []int{1, 2, 3, 4, 5}, Labels: map[string]string{"rate": "peak"},
+			},
+			{
+				Name: "day", Value: 0.2, Start: "12:00", End: "00:00",
+				Days: []int{1, 2, 3, 4, 5}, Labels: map[string]string{"rate": "day"},
+			},
+		}, windows)
+	}
+}
+
+func TestProfileTimeWindowsUnlabelled(t *testing.T) {
+	row := profileDay{Values: []float64{0.1, 0.2, 0.2, 0.3}}
+	windows, err := profileTimeWindows(profile{
+		SlotSize: 6 * time.Hour,
+		Days:     []profileDay{row, row, row, row, row, row, row},
+	})
+	if assert.NoError(t, err) {
+		week := []int{0, 1, 2, 3, 4, 5, 6}
+		assert.Equal(t, []timeWindow{
+			{Name: "slot_0000", Value: 0.1, Start: "00:00", End: "06:00", Days: week},
+			{Name: "slot_0600", Value: 0.2, Start: "06:00", End: "18:00", Days: week},
+			{Name: "slot_1800", Value: 0.3, Start: "18:00", End: "00:00", Days: week},
+		}, windows)
+		assert.NoError(t, validateWindowNames(timeOfUse{WindowActiveMetric: true, TimeWindows: windows}))
+	}
+}
+
+func TestProfileTimeWindowsValidation(t *testing.T) {
+	row := profileDay{Values: []float64{1, 2, 3, 4}}
+	week := []profileDay{row, row, row, row, row, row, row}
+
+	testCases := map[string]struct {
+		input profile
+		err   error
+	}{
+		"invalid slot size": {
+			input: profile{SlotSize: 7 * time.Hour, Days: week},
+			err:   errors.New(`Invalid profile slot size. Must be a whole number of minutes that evenly divides 24h. Got: "7h0m0s"`),
+		},
+		"missing days": {
+			input: profile{SlotSize: 6 * time.Hour, Days: week[:6]},
+			err:   errors.New("Profile must have 7 days, starting with Sunday. Got: 6"),
+		},
+		"wrong number of slots": {
+			input: profile{SlotSize: 12 * time.Hour, Days: week},
+			err:   errors.New("Profile day 0 must have 2 values for a slot size of 12h0m0s. Got: 4"),
+		},
+		"wrong number of labels": {
+			input: profile{SlotSize: 6 * time.Hour, LabelName: "rate", Days: append([]profileDay{
+				{Values: row.Values, Labels: []string{"a"}},
+			}, week[1:]...)},
+			err: errors.New("Profile day 0 must have 4 labels for a slot size of 6h0m0s. Got: 1"),
+		},
+		"labels without label name": {
+			input: profile{SlotSize: 6 * time.Hour, Days: append([]profileDay{
+				{Values: row.Values, Labels: []string{"a", "b", "c", "d"}},
+			}, week[1:]...)},
+			err: errors.New("Profile day 0 has labels, but the profile has no label_name"),
+		},
+	}
+
+	for name, tc := range testCases {
+		_, err := profileTimeWindows(tc.input)
+		assert.Equal(t, tc.err, err, name)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"slices"
+	"time"
+)
+
+// touSegment is a span of time in which the active time window of a time of
+// use doesn't change, and its value changes at most linearly.
+type touSegment struct {
+	start      time.Time
+	end        time.Time
+	startValue float64
+	endValue   float64
+	window     string
+	matched    bool
+}
+
+func (s touSegment) seconds() float64 {
+	return s.end.Sub(s.start).Seconds()
+}
+
+// integral returns the integral of the value over the segment, in
+// value-seconds.
+func (s touSegment) integral() float64 {
+	return (s.startValue + s.endValue) / 2 * s.seconds()
+}
+
+// touBreakpoints returns the times strictly between from and to at which the
+// value or the active time window of a time of use may change. Times are in
+// the location of from.
+func touBreakpoints(tou timeOfUse, from, to time.Time) []time.Time {
+	loc := from.Location()
+	points := []time.Time{}
+	add := func(t time.Time) {
+		if t.After(from) && t.Before(to) {
+			points = append(points, t)
+		}
+	}
+
+	// Start a day early to catch windows ending after midnight
+	first := time.Date(from.Year(), from.Month(), from.Day()-1, 0, 0, 0, 0, loc)
+	for day := first; !day.After(to); day = day.AddDate(0, 0, 1) {
+		// Day of week filters change at midnight
+		add(day)
+		for _, tw := range tou.TimeWindows {
+			start, end := timeWindowBounds(tw, day)
+			add(start)
+			add(end)
+			if tw.RampIn > 0 {
+				add(start.Add(tw.RampIn))
+			}
+			if tw.RampOut > 0 {
+				add(end.Add(-tw.RampOut))
+			}
+			for _, p := range tw.Points {
+				add(rampPointTime(p, day))
+			}
+		}
+	}
+
+	if f, ok := getFeed(tou.Name); ok && tou.Source != "" {
+		for _, r := range f.rows {
+			add(r.Start.In(loc))
+			add(r.End.In(loc))
+		}
+	}
+
+	slices.SortFunc(points, func(a, b time.Time) int { return a.Compare(b) })
+	return slices.CompactFunc(points, func(a, b time.Time) bool { return a.Equal(b) })
+}
+
+// touSegments splits the time between from and to into segments, using the
+// time of use's breakpoints. Values are evaluated with calculateTOUValue, so
+// from should be in the timezone of the time of use.
+func touSegments(tou timeOfUse, from, to time.Time) []touSegment {
+	if !to.After(from) {
+		return nil
+	}
+	to = to.In(from.Location())
+
+	bounds := append([]time.Time{from}, touBreakpoints(tou, from, to)...)
+	bounds = append(bounds, to)
+
+	segments := make([]touSegment, 0, len(bounds)-1)
+	for i := 0; i < len(bounds)-1; i++ {
+		start, end := bounds[i], bounds[i+1]
+		// The value is linear within a segment, so the value at the end can
+		// be derived from the midpoint without evaluating the next segment.
+		mid := start.Add(end.Sub(start) / 2)
+		startValue := calculateTOUValue(tou, start)
+		midValue := calculateTOUValue(tou, mid)
+
+		tw, matched := activeTimeWindow(tou, mid)
+		window := defaultWindowName
+		if matched {
+			window = tw.Name
+		}
+
+		segments = append(segments, touSegment{
+			start:      start,
+			end:        end,
+			startValue: startValue,
+			endValue:   2*midValue - startValue,
+			window:     window,
+			matched:    matched,
+		})
+	}
+	return segments
+}
+
+// settlementPeriodBounds returns the settlement period containing now. Periods
+// are aligned to midnight in the location of now, and the last period of the
+// day is cut short on days shortened by daylight saving.
+func settlementPeriodBounds(now time.Time, period time.Duration) (time.Time, time.Time) {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	nextMidnight := midnight.AddDate(0, 0, 1)
+
+	start := midnight.Add(now.Sub(midnight) / period * period)
+	end := start.Add(period)
+	if end.After(nextMidnight) {
+		end = nextMidnight
+	}
+	return start, end
+}
+
+// settlementValue returns the time weighted average value of a time of use
+// over the settlement period containing now. When the time of use is absent
+// when unmatched, only matched time is averaged, and false is returned if no
+// time windows match at all during the period.
+func settlementValue(tou timeOfUse, now time.Time) (float64, bool) {
+	start, end := settlementPeriodBounds(now, tou.SettlementPeriod)
+
+	integral, seconds := 0.0, 0.0
+	for _, seg := range touSegments(tou, start, end) {
+		if !seg.matched && tou.AbsentWhenUnmatched {
+			continue
+		}
+		integral += seg.integral()
+		seconds += seg.seconds()
+	}
+	if seconds == 0 {
+		return 0, false
+	}
+	return integral / seconds, true
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTOUSegments(t *testing.T) {
+	tou := timeOfUse{
+		Name:         "test",
+		DefaultValue: 1,
+		TimeWindows: []timeWindow{
+			{Name: "peak", Value: 2, Start: "07:00", End: "09:00", startHour: 7, endHour: 9},
+			{Name: "night", Value: 0.5, Start: "22:00", End: "00:00", startHour: 22},
+		},
+	}
+
+	segments := touSegments(tou,
+		time.Date(2023, 12, 1, 6, 30, 0, 0, time.UTC),
+		time.Date(2023, 12, 2, 8, 0, 0, 0, time.UTC),
+	)
+	assert.Equal(t, []touSegment{
+		{
+			start:      time.Date(2023, 12, 1, 6, 30, 0, 0, time.UTC),
+			end:        time.Date(2023, 12, 1, 7, 0, 0, 0, time.UTC),
+			startValue: 1, endValue: 1, window: "default",
+		},
+		{
+			start:      time.Date(2023, 12, 1, 7, 0, 0, 0, time.UTC),
+			end:        time.Date(2023, 12, 1, 9, 0, 0, 0, time.UTC),
+			startValue: 2, endValue: 2, window: "peak", matched: true,
+		},
+		{
+			start:      time.Date(2023, 12, 1, 9, 0, 0, 0, time.UTC),
+			end:        time.Date(2023, 12, 1, 22, 0, 0, 0, time.UTC),
+			startValue: 1, endValue: 1, window: "default",
+		},
+		{
+			start:      time.Date(2023, 12, 1, 22, 0, 0, 0, time.UTC),
+			end:        time.Date(2023, 12, 2, 0, 0, 0, 0, time.UTC),
+			startValue: 0.5, endValue: 0.5, window: "night", matched: true,
+		},
+		{
+			start:      time.Date(2023, 12, 2, 0, 0, 0, 0, time.UTC),
+			end:        time.Date(2023, 12, 2, 7, 0, 0, 0, time.UTC),
+			startValue: 1, endValue: 1, window: "default",
+		},
+		{
+			start:      time.Date(2023, 12, 2, 7, 0, 0, 0, time.UTC),
+			end:        time.Date(2023, 12, 2, 8, 0, 0, 0, time.UTC),
+			startValue: 2, endValue: 2, window: "peak", matched: true,
+		},
+	}, segments)
+
+	assert.Nil(t, touSegments(tou,
+		time.Date(2023, 12, 1, 8, 0, 0, 0, time.UTC),
+		time.Date(2023, 12, 1, 8, 0, 0, 0, time.UTC),
+	), "empty range should have no segments")
+}
+
+func TestTOUSegmentsDST(t *testing.T) {
+	auckland, err := time.LoadLocation("Pacific/Auckland")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tou := timeOfUse{Name: "test", Timezone: "Pacific/Auckland", DefaultValue: 1}
+
+	// Clocks go forward at 2am on 24 September 2023, so the day is 23h long
+	total := 0.0
+	for _, seg := range touSegments(tou,
+		time.Date(2023, 9, 24, 0, 0, 0, 0, auckland),
+		time.Date(2023, 9, 25, 0, 0, 0, 0, auckland),
+	) {
+		total += seg.integral()
+	}
+	assert.Equal(t, float64(23*60*60), total)
+}
+
+func TestSettlementValue(t *testing.T) {
+	tou := timeOfUse{
+		Name:             "test",
+		DefaultValue:     1,
+		SettlementPeriod: 30 * time.Minute,
+		TimeWindows: []timeWindow{
+			{Value: 3, Start: "07:15", End: "09:00", startHour: 7, startMinute: 15, endHour: 9},
+		},
+	}
+
+	v, ok := settlementValue(tou, time.Date(2023, 12, 1, 7, 5, 0, 0, time.UTC))
+	assert.True(t, ok)
+	assert.Equal(t, 2.0, v, "07:00-07:30 should be half default and half window")
+
+	v, ok = settlementValue(tou, time.Date(2023, 12, 1, 7, 45, 0, 0, time.UTC))
+	assert.True(t, ok)
+	assert.Equal(t, 3.0, v, "07:30-08:00 should be entirely within the window")
+
+	tou.AbsentWhenUnmatched = true
+	v, ok = settlementValue(tou, time.Date(2023, 12, 1, 7, 5, 0, 0, time.UTC))
+	assert.True(t, ok)
+	assert.Equal(t, 3.0, v, "only matched time should be averaged when absent when unmatched")
+
+	_, ok = settlementValue(tou, time.Date(2023, 12, 1, 10, 0, 0, 0, time.UTC))
+	assert.False(t, ok, "should be absent when no time window matches within the period")
+}
+
+func TestSettlementPeriodBounds(t *testing.T) {
+	auckland, err := time.LoadLocation("Pacific/Auckland")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start, end := settlementPeriodBounds(time.Date(2023, 12, 1, 7, 29, 59, 0, auckland), 30*time.Minute)
+	assert.Equal(t, time.Date(2023, 12, 1, 7, 0, 0, 0, auckland), start)
+	assert.Equal(t, time.Date(2023, 12, 1, 7, 30, 0, 0, auckland), end)
+
+	// Clocks go forward at 2am on 24 September 2023, periods stay aligned
+	start, end = settlementPeriodBounds(time.Date(2023, 9, 24, 3, 10, 0, 0, auckland), 30*time.Minute)
+	assert.Equal(t, time.Date(2023, 9, 24, 3, 0, 0, 0, auckland), start)
+	assert.Equal(t, time.Date(2023, 9, 24, 3, 30, 0, 0, auckland), end)
+
+	// Periods are cut short at midnight on short days
+	start, end = settlementPeriodBounds(time.Date(2023, 9, 24, 23, 10, 0, 0, auckland), 2*time.Hour)
+	assert.Equal(t, time.Date(2023, 9, 24, 23, 0, 0, 0, auckland), start)
+	assert.Equal(t, time.Date(2023, 9, 25, 0, 0, 0, 0, auckland), end)
+}
+
+func TestTOUSegmentsRamps(t *testing.T) {
+	tou := timeOfUse{
+		Name:         "test",
+		DefaultValue: 0,
+		TimeWindows: []timeWindow{{
+			Value:     60,
+			Start:     "07:00",
+			End:       "09:00",
+			startHour: 7,
+			endHour:   9,
+			RampIn:    time.Hour,
+		}},
+	}
+
+	segments := touSegments(tou,
+		time.Date(2023, 12, 1, 7, 0, 0, 0, time.UTC),
+		time.Date(2023, 12, 1, 9, 0, 0, 0, time.UTC),
+	)
+	if assert.Len(t, segments, 2) {
+		assert.Equal(t, 0.0, segments[0].startValue)
+		assert.Equal(t, 60.0, segments[0].endValue)
+		assert.Equal(t, float64(30*60*60), segments[0].integral(), "ramp should integrate exactly")
+		assert.Equal(t, float64(60*60*60), segments[1].integral())
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Zenith angles of solar events, in degrees. Sunrise and sunset allow for
+// atmospheric refraction and the radius of the sun's disc.
+const (
+	sunriseZenith  = 90.833
+	civilZenith    = 96
+	nauticalZenith = 102
+)
+
+// solarEvent is the time of a rising or setting solar event on a day. When
+// the sun doesn't cross the zenith of the event all day, the time is zero,
+// and polarDay says whether it stays above rather than below.
+type solarEvent struct {
+	time     time.Time
+	polarDay bool
+}
+
+func (e solarEvent) ok() bool {
+	return !e.time.IsZero()
+}
+
+// solarPosition returns the solar declination in radians, and the equation of
+// time in minutes, at a time. NOAA general solar position calculations.
+func solarPosition(t time.Time) (float64, float64) {
+	t = t.UTC()
+	hour := float64(t.Hour()) + float64(t.Minute())/60 + float64(t.Second())/3600
+	daysInYear := 365.0
+	if daysInMonth(t.Year(), time.February) == 29 {
+		daysInYear = 366
+	}
+	g := 2 * math.Pi / daysInYear * (float64(t.YearDay()-1) + (hour-12)/24)
+
+	eqtime := 229.18 * (0.000075 + 0.001868*math.Cos(g) - 0.032077*math.Sin(g) -
+		0.014615*math.Cos(2*g) - 0.040849*math.Sin(2*g))
+	decl := 0.006918 - 0.399912*math.Cos(g) + 0.070257*math.Sin(g) -
+		0.006758*math.Cos(2*g) + 0.000907*math.Sin(2*g) -
+		0.002697*math.Cos(3*g) + 0.00148*math.Sin(3*g)
+	return decl, eqtime
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func degrees(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
+
+// solarNoon returns the time of solar noon nearest to midday of the local day
+// of day, in the location of day.
+func solarNoon(lat, lon float64, day time.Time) time.Time {
+	midday := time.Date(day.Year(), day.Month(), day.Day(), 12, 0, 0, 0, day.Location())
+	noon := midday
+	// Converge on the equation of time at noon
+	for range 3 {
+		_, eqtime := solarPosition(noon)
+		utcMidnight := time.Date(noon.UTC().Year(), noon.UTC().Month(), noon.UTC().Day(), 0, 0, 0, 0, time.UTC)
+		noon = utcMidnight.Add(time.Duration((720 - 4*lon - eqtime) * float64(time.Minute)))
+		if d := noon.Sub(midday); d > 12*time.Hour {
+			noon = noon.AddDate(0, 0, -1)
+		} else if d < -12*time.Hour {
+			noon = noon.AddDate(0, 0, 1)
+		}
+	}
+	return noon.In(day.Location())
+}
+
+// solarEventTime returns the time the sun crosses the zenith, in degrees, on
+// the local day of day, either rising in the morning or setting in the
+// evening.
+func solarEventTime(lat, lon float64, day time.Time, zenith float64, rising bool) solarEvent {
+	noon := solarNoon(lat, lon, day)
+	t := noon
+	// Converge on the declination at the time of the event
+	for range 3 {
+		decl, _ := solarPosition(t)
+		cosHA := math.Cos(radians(zenith))/(math.Cos(radians(lat))*math.Cos(decl)) - math.Tan(radians(lat))*math.Tan(decl)
+		if cosHA > 1 {
+			return solarEvent{}
+		}
+		if cosHA < -1 {
+			return solarEvent{polarDay: true}
+		}
+		// 4 minutes per degree of hour angle
+		offset := time.Duration(4 * degrees(math.Acos(cosHA)) * float64(time.Minute))
+		if rising {
+			t = noon.Add(-offset)
+		} else {
+			t = noon.Add(offset)
+		}
+	}
+	return solarEvent{time: t}
+}
+
+// solarElevation returns the elevation of the sun above the horizon in
+// degrees, without refraction.
+func solarElevation(lat, lon float64, t time.Time) float64 {
+	decl, eqtime := solarPosition(t)
+	utc := t.UTC()
+	minutes := float64(utc.Hour()*60+utc.Minute()) + float64(utc.Second())/60
+	trueSolarTime := minutes + eqtime + 4*lon
+	ha := radians(trueSolarTime/4 - 180)
+
+	cosZenith := math.Sin(radians(lat))*math.Sin(decl) + math.Cos(radians(lat))*math.Cos(decl)*math.Cos(ha)
+	return 90 - degrees(math.Acos(math.Max(-1, math.Min(1, cosZenith))))
+}
+
+// daylightDuration returns the time between sunrise and sunset on the local
+// day of day, which is the whole day during polar day, and zero during polar
+// night.
+func daylightDuration(lat, lon float64, day time.Time) time.Duration {
+	sunrise := solarEventTime(lat, lon, day, sunriseZenith, true)
+	sunset := solarEventTime(lat, lon, day, sunriseZenith, false)
+	if !sunrise.ok() || !sunset.ok() {
+		if sunrise.polarDay {
+			return 24 * time.Hour
+		}
+		return 0
+	}
+	return sunset.time.Sub(sunrise.time)
+}
+
+// solarAnchor is a time window start or end relative to a solar event at a
+// location, such as sunrise+30m.
+type solarAnchor struct {
+	event     string
+	offset    time.Duration
+	latitude  float64
+	longitude float64
+}
+
+// solarAnchorEvents are the solar events time windows can be relative to,
+// with their zenith and whether the sun is rising.
+var solarAnchorEvents = map[string]struct {
+	zenith float64
+	rising bool
+}{
+	"sunrise":       {sunriseZenith, true},
+	"sunset":        {sunriseZenith, false},
+	"civil_dawn":    {civilZenith, true},
+	"civil_dusk":    {civilZenith, false},
+	"nautical_dawn": {nauticalZenith, true},
+	"nautical_dusk": {nauticalZenith, false},
+	"solar_noon":    {},
+}
+
+// parseSolarAnchor parses a time relative to a solar event, such as sunset-1h.
+// Returns nil if the time isn't relative to a solar event.
+func parseSolarAnchor(t string, loc *location) (*solarAnchor, error) {
+	i := strings.IndexAny(t, "+-")
+	event, offset := t, ""
+	if i >= 0 {
+		event, offset = t[:i], t[i:]
+	}
+	if _, ok := solarAnchorEvents[event]; !ok {
+		return nil, nil
+	}
+	if loc == nil {
+		return nil, fmt.Errorf(`Time "%s" is relative to a solar event, so the time of use must have a location`, t)
+	}
+
+	a := &solarAnchor{event: event, latitude: loc.Latitude, longitude: loc.Longitude}
+	if offset != "" {
+		d, err := time.ParseDuration(offset)
+		if err != nil {
+			return nil, fmt.Errorf(`Invalid solar event offset. Must be a duration such as +30m or -1h. Got: "%s"`, offset)
+		}
+		a.offset = d
+	}
+	return a, nil
+}
+
+// time returns the time of the anchor on the local day of day, clamped to the
+// day. During polar day, rising events are at the start of the day and setting
+// events at the end of the day. Returns false when the event doesn't happen
+// during polar night.
+func (a solarAnchor) time(day time.Time) (time.Time, bool) {
+	midnight := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	nextMidnight := midnight.AddDate(0, 0, 1)
+
+	var t time.Time
+	e := solarAnchorEvents[a.event]
+	if a.event == "solar_noon" {
+		t = solarNoon(a.latitude, a.longitude, day)
+	} else if event := solarEventTime(a.latitude, a.longitude, day, e.zenith, e.rising); event.ok() {
+		t = event.time
+	} else if event.polarDay && e.rising {
+		t = midnight
+	} else if event.polarDay {
+		t = nextMidnight
+	} else {
+		return solarNoon(a.latitude, a.longitude, day), false
+	}
+
+	t = t.Add(a.offset)
+	if t.Before(midnight) {
+		return midnight, true
+	}
+	if t.After(nextMidnight) {
+		return nextMidnight, true
+	}
+	return t, true
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	londonLat, londonLon = 51.5074, -0.1278
+	tromsoLat, tromsoLon = 69.6496, 18.9560
+	solarTestTolerance   = 2 * time.Minute
+)
+
+func assertNear(t *testing.T, expected, actual time.Time, msg string) {
+	assert.WithinDuration(t, expected, actual, solarTestTolerance, msg)
+}
+
+func TestSolarEventTime(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2024, 6, 21, 15, 0, 0, 0, london)
+
+	assertNear(t, time.Date(2024, 6, 21, 4, 43, 0, 0, london), solarEventTime(londonLat, londonLon, day, sunriseZenith, true).time, "sunrise")
+	assertNear(t, time.Date(2024, 6, 21, 21, 21, 0, 0, london), solarEventTime(londonLat, londonLon, day, sunriseZenith, false).time, "sunset")
+	assertNear(t, time.Date(2024, 6, 21, 3, 55, 0, 0, london), solarEventTime(londonLat, londonLon, day, civilZenith, true).time, "civil dawn")
+	assertNear(t, time.Date(2024, 6, 21, 22, 9, 0, 0, london), solarEventTime(londonLat, londonLon, day, civilZenith, false).time, "civil dusk")
+	assertNear(t, time.Date(2024, 6, 21, 13, 2, 0, 0, london), solarNoon(londonLat, londonLon, day), "solar noon")
+
+	winter := time.Date(2024, 12, 21, 0, 0, 0, 0, london)
+	assertNear(t, time.Date(2024, 12, 21, 8, 4, 0, 0, london), solarEventTime(londonLat, londonLon, winter, sunriseZenith, true).time, "winter sunrise")
+	assertNear(t, time.Date(2024, 12, 21, 15, 54, 0, 0, london), solarEventTime(londonLat, londonLon, winter, sunriseZenith, false).time, "winter sunset")
+}
+
+func TestSolarEventTimePolar(t *testing.T) {
+	oslo, err := time.LoadLocation("Europe/Oslo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	summer := time.Date(2024, 6, 21, 12, 0, 0, 0, oslo)
+	sunrise := solarEventTime(tromsoLat, tromsoLon, summer, sunriseZenith, true)
+	assert.False(t, sunrise.ok(), "no sunrise in polar day")
+	assert.True(t, sunrise.polarDay)
+	assert.Equal(t, 24*time.Hour, daylightDuration(tromsoLat, tromsoLon, summer))
+
+	winter := time.Date(2024, 12, 21, 12, 0, 0, 0, oslo)
+	sunrise = solarEventTime(tromsoLat, tromsoLon, winter, sunriseZenith, true)
+	assert.False(t, sunrise.ok(), "no sunrise in polar night")
+	assert.False(t, sunrise.polarDay)
+	assert.Equal(t, time.Duration(0), daylightDuration(tromsoLat, tromsoLon, winter))
+	assert.True(t, solarEventTime(tromsoLat, tromsoLon, winter, civilZenith, true).ok(), "civil twilight in polar night")
+}
+
+func TestSolarEventTimeDateLine(t *testing.T) {
+	// UTC+14, far from the longitude of the timezone
+	kiritimati, err := time.LoadLocation("Pacific/Kiritimati")
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2024, 3, 20, 0, 0, 0, 0, kiritimati)
+	sunrise := solarEventTime(1.87, -157.36, day, sunriseZenith, true)
+	if assert.True(t, sunrise.ok()) {
+		assert.Equal(t, 20, sunrise.time.Day(), "should be on the local day")
+	}
+	noon := solarNoon(1.87, -157.36, day)
+	assert.Equal(t, 20, noon.Day())
+	assert.Equal(t, 12, noon.Hour())
+}
+
+func TestSolarElevation(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Fatal(err)
+	}
+	noon := solarNoon(londonLat, londonLon, time.Date(2024, 6, 21, 0, 0, 0, 0, london))
+	assert.InDelta(t, 90-londonLat+23.44, solarElevation(londonLat, londonLon, noon), 0.1)
+	assert.Less(t, solarElevation(londonLat, londonLon, time.Date(2024, 6, 21, 1, 0, 0, 0, london)), 0.0)
+}
+
+func TestCollectLocations(t *testing.T) {
+	defer setLiveConfig(getLiveConfig())
+	setLiveConfig(config{Locations: []location{
+		{Name: "london", Latitude: londonLat, Longitude: londonLon, Timezone: "Europe/London"},
+		{Name: "tromso", Latitude: tromsoLat, Longitude: tromsoLon, Timezone: "Europe/Oslo"},
+	}})
+
+	testCh := make(chan prometheus.Metric)
+	go func() {
+		collectLocations(testCh, getLiveConfig(), time.Date(2024, 6, 21, 12, 0, 0, 0, time.UTC))
+		close(testCh)
+	}()
+
+	counts := map[string]int{}
+	for m := range testCh {
+		actual := &dto.Metric{}
+		if err := m.Write(actual); err != nil {
+			t.Fatal(err)
+		}
+		for _, l := range actual.GetLabel() {
+			if l.GetName() == "location" {
+				counts[l.GetValue()]++
+				if m.Desc().String() == solarIsDaylight.desc(getLiveConfig().MetricNaming).String() {
+					assert.Equal(t, 1.0, actual.GetGauge().GetValue(), l.GetValue())
+				}
+			}
+		}
+	}
+	assert.Equal(t, 10, counts["london"])
+	assert.Equal(t, 4, counts["tromso"], "no sunrise, sunset or twilight in polar day")
+}
+
+func TestValidateLocation(t *testing.T) {
+	london := location{Name: "london", Latitude: londonLat, Longitude: londonLon}
+	assert.NoError(t, validateLocation(london, nil))
+	assert.Equal(t, errors.New("Locations must have a name"), validateLocation(location{}, nil))
+	assert.Equal(t, errors.New(`Duplicate location name "london"`), validateLocation(london, []location{london}))
+	assert.Equal(t, errors.New("Location latitude must be -90 to 90. Got: 91"), validateLocation(location{Name: "north", Latitude: 91}, nil))
+	assert.Equal(t, errors.New("Location longitude must be -180 to 180. Got: -181"), validateLocation(location{Name: "west", Longitude: -181}, nil))
+}
+
+func TestParseSolarAnchor(t *testing.T) {
+	london := &location{Name: "london", Latitude: londonLat, Longitude: londonLon}
+
+	a, err := parseSolarAnchor("sunrise+30m", london)
+	if assert.NoError(t, err) {
+		assert.Equal(t, &solarAnchor{event: "sunrise", offset: 30 * time.Minute, latitude: londonLat, longitude: londonLon}, a)
+	}
+	a, err = parseSolarAnchor("civil_dusk-1h15m", london)
+	if assert.NoError(t, err) {
+		assert.Equal(t, -75*time.Minute, a.offset)
+	}
+	a, err = parseSolarAnchor("07:00", london)
+	assert.NoError(t, err)
+	assert.Nil(t, a, "clock times aren't solar anchors")
+
+	_, err = parseSolarAnchor("sunset", nil)
+	assert.Equal(t, errors.New(`Time "sunset" is relative to a solar event, so the time of use must have a location`), err)
+	_, err = parseSolarAnchor("sunset+soon", london)
+	assert.Equal(t, errors.New(`Invalid solar event offset. Must be a duration such as +30m or -1h. Got: "+soon"`), err)
+}
+
+func TestSolarTimeWindow(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tw := timeWindow{
+		Start:      "sunrise+30m",
+		End:        "sunset-1h",
+		startSolar: &solarAnchor{event: "sunrise", offset: 30 * time.Minute, latitude: londonLat, longitude: londonLon},
+		endSolar:   &solarAnchor{event: "sunset", offset: -time.Hour, latitude: londonLat, longitude: londonLon},
+	}
+
+	start, end := timeWindowBounds(tw, time.Date(2024, 6, 21, 12, 0, 0, 0, london))
+	assertNear(t, time.Date(2024, 6, 21, 5, 13, 0, 0, london), start, "summer start")
+	assertNear(t, time.Date(2024, 6, 21, 20, 21, 0, 0, london), end, "summer end")
+
+	assert.True(t, isWithinTimeWindow(tw, time.Date(2024, 6, 21, 6, 0, 0, 0, london)))
+	assert.False(t, isWithinTimeWindow(tw, time.Date(2024, 12, 21, 6, 0, 0, 0, london)), "before sunrise in winter")
+	assert.False(t, isWithinTimeWindow(tw, time.Date(2024, 12, 21, 15, 30, 0, 0, london)), "an hour before sunset in winter")
+}
+
+func TestSolarTimeWindowPolar(t *testing.T) {
+	oslo, err := time.LoadLocation("Europe/Oslo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tw := timeWindow{
+		Start:      "sunrise",
+		End:        "sunset",
+		startSolar: &solarAnchor{event: "sunrise", latitude: tromsoLat, longitude: tromsoLon},
+		endSolar:   &solarAnchor{event: "sunset", latitude: tromsoLat, longitude: tromsoLon},
+	}
+
+	start, end := timeWindowBounds(tw, time.Date(2024, 6, 21, 12, 0, 0, 0, oslo))
+	assert.Equal(t, time.Date(2024, 6, 21, 0, 0, 0, 0, oslo), start, "polar day starts at midnight")
+	assert.Equal(t, time.Date(2024, 6, 22, 0, 0, 0, 0, oslo), end, "polar day ends at midnight")
+
+	start, end = timeWindowBounds(tw, time.Date(2024, 12, 21, 12, 0, 0, 0, oslo))
+	assert.Equal(t, start, end, "polar night is empty")
+	assert.False(t, isWithinTimeWindow(tw, time.Date(2024, 12, 21, 12, 0, 0, 0, oslo)))
+}
+
+func TestSolarTimeWindowPolarNightOffsets(t *testing.T) {
+	oslo, err := time.LoadLocation("Europe/Oslo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tw := timeWindow{
+		Start:      "sunset-1h",
+		End:        "sunset",
+		startSolar: &solarAnchor{event: "sunset", offset: -time.Hour, latitude: tromsoLat, longitude: tromsoLon},
+		endSolar:   &solarAnchor{event: "sunset", latitude: tromsoLat, longitude: tromsoLon},
+	}
+	start, end := timeWindowBounds(tw, time.Date(2024, 12, 21, 12, 0, 0, 0, oslo))
+	assert.Equal(t, start, end, "polar night is empty")
+	for h := range 24 {
+		assert.False(t, isWithinTimeWindow(tw, time.Date(2024, 12, 21, h, 30, 0, 0, oslo)), h)
+	}
+
+	// Windows from a fixed time to an event which doesn't happen are empty too
+	tw = timeWindow{
+		Start:     "06:00",
+		End:       "sunset+2h",
+		startHour: 6,
+		endSolar:  &solarAnchor{event: "sunset", offset: 2 * time.Hour, latitude: tromsoLat, longitude: tromsoLon},
+	}
+	start, end = timeWindowBounds(tw, time.Date(2024, 12, 21, 12, 0, 0, 0, oslo))
+	assert.Equal(t, start, end, "polar night is empty")
+	assert.False(t, isWithinTimeWindow(tw, time.Date(2024, 12, 21, 10, 0, 0, 0, oslo)))
+}
+
+func TestLoadConfigSolarTimeWindows(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "config_test.*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(f.Name(), []byte(`
+locations:
+- name: london
+  latitude: 51.5074
+  longitude: -0.1278
+  timezone: Europe/London
+time_of_use:
+- name: solar_test
+  timezone: Europe/London
+  location: london
+  default_value: 0.3
+  time_windows:
+  - value: 0.05
+    start: sunrise+30m
+    end: sunset-1h
+    ramp_in: 30m
+`), 0644)
+
+	c, err := loadConfig(f.Name())
+	if assert.NoError(t, err) {
+		london, _ := time.LoadLocation("Europe/London")
+		tou := c.TimeOfUse[0]
+		assert.Equal(t, 0.05, calculateTOUValue(tou, time.Date(2024, 6, 21, 12, 0, 0, 0, london)))
+		assert.Equal(t, 0.3, calculateTOUValue(tou, time.Date(2024, 6, 21, 22, 0, 0, 0, london)))
+	}
+
+	os.WriteFile(f.Name(), []byte(`
+time_of_use:
+- name: solar_test
+  location: paris
+`), 0644)
+	_, err = loadConfig(f.Name())
+	assert.Equal(t, errors.New(`Unknown location "paris"`), err)
+
+	os.WriteFile(f.Name(), []byte(`
+locations:
+- name: london
+  latitude: 51.5074
+  longitude: -0.1278
+time_of_use:
+- name: solar_test
+  location: london
+  time_windows:
+  - start: sunrise
+    end: '12:00'
+    points:
+    - time: '11:00'
+      value: 1
+`), 0644)
+	_, err = loadConfig(f.Name())
+	assert.Equal(t, errors.New("Time windows relative to solar events can not have points"), err)
+}
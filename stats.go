@@ -0,0 +1,112 @@
+package main
+
+import (
+	"math"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// statisticsRanges are the ranges of time statistics can be reported over.
+var statisticsRanges = []string{"day", "next_24h"}
+
+// touStatistics summarises the value of a time of use over a range of time.
+type touStatistics struct {
+	min  float64
+	max  float64
+	mean float64
+	// Percentage of the range with a value lower than the current value
+	currentPercentile float64
+}
+
+// statisticsRangeBounds returns the range of time statistics are calculated
+// over. Days are aligned to midnight in the location of now.
+func statisticsRangeBounds(r string, now time.Time) (time.Time, time.Time) {
+	if r == "day" {
+		midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		return midnight, midnight.AddDate(0, 0, 1)
+	}
+	return now, now.Add(24 * time.Hour)
+}
+
+// calculateTOUStatistics calculates statistics of a time of use from its
+// schedule between from and to, ranking the given current value. Unmatched
+// time is skipped when unmatched values are absent. Returns false if there's
+// no value over the whole range.
+func calculateTOUStatistics(tou timeOfUse, from, to time.Time, current float64) (touStatistics, bool) {
+	stats := touStatistics{min: math.Inf(1), max: math.Inf(-1)}
+	var seconds, integral, below float64
+	for _, seg := range touSegments(tou, from, to) {
+		if !seg.matched && tou.AbsentWhenUnmatched {
+			continue
+		}
+		stats.min = math.Min(stats.min, math.Min(seg.startValue, seg.endValue))
+		stats.max = math.Max(stats.max, math.Max(seg.startValue, seg.endValue))
+		seconds += seg.seconds()
+		integral += seg.integral()
+		below += seg.seconds() * segmentFractionBelow(seg, current)
+	}
+	if seconds == 0 {
+		return touStatistics{}, false
+	}
+	stats.mean = integral / seconds
+	stats.currentPercentile = below / seconds * 100
+	return stats, true
+}
+
+// segmentFractionBelow returns the fraction of a segment with a value lower
+// than v. Values change linearly within a segment.
+func segmentFractionBelow(seg touSegment, v float64) float64 {
+	if seg.startValue == seg.endValue {
+		if seg.startValue < v {
+			return 1
+		}
+		return 0
+	}
+	f := math.Max(0, math.Min(1, (v-seg.startValue)/(seg.endValue-seg.startValue)))
+	if seg.endValue < seg.startValue {
+		return 1 - f
+	}
+	return f
+}
+
+type statisticsDescs struct {
+	min               *prometheus.Desc
+	max               *prometheus.Desc
+	mean              *prometheus.Desc
+	currentPercentile *prometheus.Desc
+}
+
+func describeStatisticsMetrics(n metricNaming, tou timeOfUse, r string) statisticsDescs {
+	labels := n.touConstLabels(tou)
+	over := "the current day"
+	percentileName := n.touMetricName(tou) + "_current_percentile"
+	if r == "next_24h" {
+		over = "the next 24h"
+		percentileName = n.touMetricName(tou) + "_next_24h_current_percentile"
+	}
+	return statisticsDescs{
+		min:  prometheus.NewDesc(n.touMetricName(tou)+"_"+r+"_min", "Minimum value of "+tou.Name+" over "+over, nil, labels),
+		max:  prometheus.NewDesc(n.touMetricName(tou)+"_"+r+"_max", "Maximum value of "+tou.Name+" over "+over, nil, labels),
+		mean: prometheus.NewDesc(n.touMetricName(tou)+"_"+r+"_mean", "Time weighted mean value of "+tou.Name+" over "+over, nil, labels),
+		currentPercentile: prometheus.NewDesc(percentileName,
+			"Percentage of "+over+" in which "+tou.Name+" is lower than the current value", nil, labels),
+	}
+}
+
+func collectStatisticsMetrics(ch chan<- prometheus.Metric, n metricNaming, tou timeOfUse, now time.Time, current float64, hasCurrent bool) {
+	for _, r := range tou.Statistics {
+		descs := describeStatisticsMetrics(n, tou, r)
+		from, to := statisticsRangeBounds(r, now)
+		stats, ok := calculateTOUStatistics(tou, from, to, current)
+		if !ok {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(descs.min, prometheus.GaugeValue, stats.min)
+		ch <- prometheus.MustNewConstMetric(descs.max, prometheus.GaugeValue, stats.max)
+		ch <- prometheus.MustNewConstMetric(descs.mean, prometheus.GaugeValue, stats.mean)
+		if hasCurrent {
+			ch <- prometheus.MustNewConstMetric(descs.currentPercentile, prometheus.GaugeValue, stats.currentPercentile)
+		}
+	}
+}
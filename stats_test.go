@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+var statsTestTOU = timeOfUse{
+	Name:         "stats_test",
+	DefaultValue: 1,
+	Statistics:   []string{"day", "next_24h"},
+	TimeWindows: []timeWindow{
+		{Value: 3, startHour: 7, endHour: 9},
+		{Value: 2, startHour: 17, endHour: 21},
+	},
+}
+
+func TestCalculateTOUStatistics(t *testing.T) {
+	from, to := statisticsRangeBounds("day", time.Date(2023, 12, 1, 18, 0, 0, 0, time.UTC))
+	stats, ok := calculateTOUStatistics(statsTestTOU, from, to, 2)
+	if assert.True(t, ok) {
+		assert.Equal(t, 1.0, stats.min)
+		assert.Equal(t, 3.0, stats.max)
+		assert.InDelta(t, 32.0/24, stats.mean, 1e-9)
+		assert.InDelta(t, 75.0, stats.currentPercentile, 1e-9)
+	}
+
+	// From 18:00 to 18:00 on Saturday, which only has the morning window
+	weekdays := statsTestTOU
+	weekdays.TimeWindows = []timeWindow{
+		{Value: 3, startHour: 7, endHour: 9},
+		{Value: 2, startHour: 17, endHour: 21, Days: []int{5}},
+	}
+	from, to = statisticsRangeBounds("next_24h", time.Date(2023, 12, 1, 18, 0, 0, 0, time.UTC))
+	stats, ok = calculateTOUStatistics(weekdays, from, to, 2)
+	if assert.True(t, ok) {
+		assert.InDelta(t, 31.0/24, stats.mean, 1e-9)
+		assert.InDelta(t, 19.0/24*100, stats.currentPercentile, 1e-9)
+	}
+
+	absent := statsTestTOU
+	absent.AbsentWhenUnmatched = true
+	from, to = statisticsRangeBounds("day", time.Date(2023, 12, 1, 18, 0, 0, 0, time.UTC))
+	stats, ok = calculateTOUStatistics(absent, from, to, 2)
+	if assert.True(t, ok) {
+		assert.Equal(t, 2.0, stats.min, "unmatched time should be skipped")
+		assert.InDelta(t, 14.0/6, stats.mean, 1e-9)
+		assert.InDelta(t, 0.0, stats.currentPercentile, 1e-9)
+	}
+}
+
+func TestSegmentFractionBelow(t *testing.T) {
+	start := time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC)
+	rising := touSegment{start: start, end: start.Add(time.Hour), startValue: 0, endValue: 4}
+	assert.InDelta(t, 0.25, segmentFractionBelow(rising, 1), 1e-9)
+	assert.Equal(t, 1.0, segmentFractionBelow(rising, 5))
+
+	falling := touSegment{start: start, end: start.Add(time.Hour), startValue: 4, endValue: 0}
+	assert.InDelta(t, 0.25, segmentFractionBelow(falling, 1), 1e-9)
+	assert.Equal(t, 0.0, segmentFractionBelow(falling, -1))
+
+	flat := touSegment{start: start, end: start.Add(time.Hour), startValue: 2, endValue: 2}
+	assert.Equal(t, 0.0, segmentFractionBelow(flat, 2))
+}
+
+func TestCollectStatisticsMetrics(t *testing.T) {
+	testCh := make(chan prometheus.Metric)
+	go func() {
+		collectStatisticsMetrics(testCh, metricNaming{}, statsTestTOU, time.Date(2023, 12, 1, 18, 0, 0, 0, time.UTC), 2, true)
+		close(testCh)
+	}()
+
+	values := map[string]float64{}
+	for m := range testCh {
+		actual := &dto.Metric{}
+		if err := m.Write(actual); err != nil {
+			t.Fatal(err)
+		}
+		values[strings.Split(m.Desc().String(), `"`)[1]] = actual.GetGauge().GetValue()
+	}
+
+	assert.Len(t, values, 8)
+	assert.Equal(t, 1.0, values["stats_test_day_min"])
+	assert.Equal(t, 3.0, values["stats_test_day_max"])
+	assert.InDelta(t, 75.0, values["stats_test_current_percentile"], 1e-9)
+	assert.InDelta(t, 32.0/24, values["stats_test_next_24h_mean"], 1e-9)
+}
+
+func TestValidateStatistics(t *testing.T) {
+	assert.NoError(t, validateStatistics([]string{"day", "next_24h"}))
+	assert.Equal(t, errors.New(`Unknown statistics range "week". Must be day or next_24h`), validateStatistics([]string{"week"}))
+}
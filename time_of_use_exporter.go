@@ -17,6 +17,10 @@ import (
 type Exporter struct{}
 
 func main() {
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:], os.Stdout))
+	}
+
 	var logLevel slog.Level
 	switch os.Getenv("LOG_LEVEL") {
 	case "debug":
@@ -42,6 +46,7 @@ func main() {
 	prometheus.MustRegister(&Exporter{})
 
 	http.Handle("/metrics", promhttp.Handler())
+	registerAPIHandlers(http.DefaultServeMux)
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(`<html>
 			<head><title>Time of Use Exporter</title></head>
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Fixed offsets east of UTC, such as +05:30, -03 or UTC+05:30
+var utcOffsetRegexp = regexp.MustCompile(`^(?:UTC)?([+-])(\d{1,2})(?::?(\d{2}))?$`)
+
+// Strings starting with an abbreviation and offset, such as NZST-12
+var posixTZRegexp = regexp.MustCompile(`^(?:<[^>]*>|[a-zA-Z]+)[+\-0-9]`)
+
+// Maximum distance of a fixed offset from UTC
+const maxUTCOffset = 14 * time.Hour
+
+// Years POSIX TZ string transitions are listed for. Go applies the POSIX TZ
+// string to times after the last listed transition, but then reports the
+// start of each year as a zone boundary, so transitions are listed explicitly.
+const (
+	posixTZFirstYear = 1970
+	posixTZLastYear  = 2100
+)
+
+// loadLocation loads a timezone, which is either an IANA timezone name, a
+// fixed offset from UTC, or a POSIX TZ string. An empty name is UTC.
+func loadLocation(name string) (*time.Location, error) {
+	if loc, err := time.LoadLocation(name); err == nil {
+		return loc, nil
+	}
+
+	if m := utcOffsetRegexp.FindStringSubmatch(name); m != nil {
+		hours, _ := strconv.Atoi(m[2])
+		minutes := 0
+		if m[3] != "" {
+			minutes, _ = strconv.Atoi(m[3])
+		}
+		offset := time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute
+		if minutes >= 60 || offset > maxUTCOffset {
+			return nil, fmt.Errorf(`Invalid UTC offset. Must be between -14:00 and +14:00. Got: "%s"`, name)
+		}
+		if m[1] == "-" {
+			offset = -offset
+		}
+		return time.FixedZone(name, int(offset.Seconds())), nil
+	}
+
+	if posixTZRegexp.MatchString(name) {
+		tz, err := parsePOSIXTZ(name)
+		if err != nil {
+			return nil, fmt.Errorf(`Invalid POSIX TZ string, %s. Must be std offset [dst [offset] [,start[/time],end[/time]]], such as NZST-12NZDT,M9.5.0,M4.1.0/3. Got: "%s"`, err, name)
+		}
+		return time.LoadLocationFromTZData(name, posixTZData(name, tz.zones(), tz.transitions()))
+	}
+
+	return nil, fmt.Errorf(`Unknown timezone. Must be an IANA timezone such as Pacific/Auckland, a UTC offset such as +05:30 or UTC+05:30, or a POSIX TZ string such as NZST-12NZDT,M9.5.0,M4.1.0/3. Got: "%s"`, name)
+}
+
+// posixTZ is a parsed POSIX TZ string. Offsets are east of UTC in seconds.
+type posixTZ struct {
+	std       string
+	stdOffset int
+	dst       string
+	dstOffset int
+	// Start and end of daylight saving time, if there is a dst zone
+	start posixTZRule
+	end   posixTZRule
+}
+
+// posixTZRule is the date and local time of a daylight saving time transition.
+type posixTZRule struct {
+	// J for a Julian day from 1-365 not counting 29 February, N for a day from
+	// 0-365 counting it, or M for a weekday from 0-6 of a week of a month
+	kind  byte
+	day   int
+	week  int
+	month int
+	// Seconds after local midnight
+	time int
+}
+
+// Rules used without start and end rules, which are the US rules
+var posixTZDefaultRules = [2]posixTZRule{
+	{kind: 'M', month: 3, week: 2, time: 2 * 60 * 60},
+	{kind: 'M', month: 11, week: 1, time: 2 * 60 * 60},
+}
+
+// parsePOSIXTZ parses a POSIX TZ string. Offsets in the string are west of
+// UTC, so NZST-12 is 12 hours ahead of UTC.
+func parsePOSIXTZ(s string) (posixTZ, error) {
+	p := &posixTZParser{s: s}
+	std, err := p.name()
+	if err != nil {
+		return posixTZ{}, err
+	}
+	offset, err := p.offset(24)
+	if err != nil {
+		return posixTZ{}, err
+	}
+	tz := posixTZ{std: std, stdOffset: -offset}
+	if p.s == "" {
+		return tz, nil
+	}
+
+	if tz.dst, err = p.name(); err != nil {
+		return posixTZ{}, err
+	}
+	// Daylight saving time defaults to an hour ahead of standard time
+	tz.dstOffset = tz.stdOffset + 60*60
+	if p.s != "" && p.s[0] != ',' {
+		offset, err := p.offset(24)
+		if err != nil {
+			return posixTZ{}, err
+		}
+		tz.dstOffset = -offset
+	}
+	// Without rules, daylight saving time uses the US rules
+	if p.s == "" {
+		tz.start, tz.end = posixTZDefaultRules[0], posixTZDefaultRules[1]
+		return tz, nil
+	}
+
+	for _, rule := range []*posixTZRule{&tz.start, &tz.end} {
+		if !strings.HasPrefix(p.s, ",") {
+			return posixTZ{}, errors.New("expected start and end rules after a comma")
+		}
+		p.s = p.s[1:]
+		if *rule, err = p.rule(); err != nil {
+			return posixTZ{}, err
+		}
+	}
+	if p.s != "" {
+		return posixTZ{}, fmt.Errorf(`unexpected "%s" after the end rule`, p.s)
+	}
+	return tz, nil
+}
+
+// posixTZParser consumes the fields of a POSIX TZ string from s.
+type posixTZParser struct {
+	s string
+}
+
+// name parses a zone abbreviation of 3 or more letters, or of letters, digits
+// and signs in angle brackets, such as <+0530>.
+func (p *posixTZParser) name() (string, error) {
+	if strings.HasPrefix(p.s, "<") {
+		end := strings.IndexByte(p.s, '>')
+		if end < 0 {
+			return "", errors.New("unterminated quoted abbreviation")
+		}
+		name := p.s[1:end]
+		if len(name) < 3 || strings.IndexFunc(name, func(r rune) bool {
+			return !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || r == '+' || r == '-')
+		}) >= 0 {
+			return "", fmt.Errorf(`invalid quoted abbreviation "%s"`, name)
+		}
+		p.s = p.s[end+1:]
+		return name, nil
+	}
+
+	end := strings.IndexFunc(p.s, func(r rune) bool { return !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z') })
+	if end < 0 {
+		end = len(p.s)
+	}
+	if end < 3 {
+		return "", errors.New("zone abbreviations must be at least 3 letters")
+	}
+	name := p.s[:end]
+	p.s = p.s[end:]
+	return name, nil
+}
+
+// offset parses a signed [+-]hh[:mm[:ss]] duration in seconds, with at most
+// maxHours hours.
+func (p *posixTZParser) offset(maxHours int) (int, error) {
+	sign := 1
+	if p.s != "" && (p.s[0] == '+' || p.s[0] == '-') {
+		if p.s[0] == '-' {
+			sign = -1
+		}
+		p.s = p.s[1:]
+	}
+
+	hours, ok := p.number()
+	if !ok || hours > maxHours {
+		return 0, fmt.Errorf("expected an offset of hours from 0-%d", maxHours)
+	}
+	seconds := hours * 60 * 60
+	for _, unit := range []int{60, 1} {
+		if !strings.HasPrefix(p.s, ":") {
+			break
+		}
+		p.s = p.s[1:]
+		n, ok := p.number()
+		if !ok || n > 59 {
+			return 0, errors.New("expected minutes and seconds from 0-59 in offset")
+		}
+		seconds += n * unit
+	}
+	return sign * seconds, nil
+}
+
+// rule parses a daylight saving time transition date, with an optional time
+// of day which defaults to 02:00.
+func (p *posixTZParser) rule() (posixTZRule, error) {
+	rule := posixTZRule{time: 2 * 60 * 60}
+	switch {
+	case strings.HasPrefix(p.s, "J"):
+		p.s = p.s[1:]
+		day, ok := p.number()
+		if !ok || day < 1 || day > 365 {
+			return posixTZRule{}, errors.New("expected a Julian day from 1-365 after J")
+		}
+		rule.kind, rule.day = 'J', day
+	case strings.HasPrefix(p.s, "M"):
+		p.s = p.s[1:]
+		rule.kind = 'M'
+		for i, field := range []*int{&rule.month, &rule.week, &rule.day} {
+			if i > 0 {
+				if !strings.HasPrefix(p.s, ".") {
+					return posixTZRule{}, errors.New("expected month rule Mm.w.d")
+				}
+				p.s = p.s[1:]
+			}
+			n, ok := p.number()
+			if !ok || n > []int{12, 5, 6}[i] || i < 2 && n < 1 {
+				return posixTZRule{}, errors.New("expected month rule Mm.w.d, with month from 1-12, week from 1-5 and day from 0-6")
+			}
+			*field = n
+		}
+	default:
+		day, ok := p.number()
+		if !ok || day > 365 {
+			return posixTZRule{}, errors.New("expected a rule of Jn, n or Mm.w.d")
+		}
+		rule.kind, rule.day = 'N', day
+	}
+
+	if strings.HasPrefix(p.s, "/") {
+		p.s = p.s[1:]
+		t, err := p.offset(167)
+		if err != nil {
+			return posixTZRule{}, err
+		}
+		rule.time = t
+	}
+	return rule, nil
+}
+
+// number parses a decimal number of up to 3 digits.
+func (p *posixTZParser) number() (int, bool) {
+	end := strings.IndexFunc(p.s, func(r rune) bool { return r < '0' || r > '9' })
+	if end < 0 {
+		end = len(p.s)
+	}
+	if end == 0 || end > 3 {
+		return 0, false
+	}
+	n, _ := strconv.Atoi(p.s[:end])
+	p.s = p.s[end:]
+	return n, true
+}
+
+// tzZone is a zone of TZif data, with its offset east of UTC in seconds.
+type tzZone struct {
+	name   string
+	offset int
+	isDST  bool
+}
+
+// tzTransition is the Unix time a zone, by index, starts at.
+type tzTransition struct {
+	at   int64
+	zone int
+}
+
+// zones returns the zones of a POSIX TZ string, standard time first.
+func (tz posixTZ) zones() []tzZone {
+	zones := []tzZone{{name: tz.std, offset: tz.stdOffset}}
+	if tz.dst != "" {
+		zones = append(zones, tzZone{name: tz.dst, offset: tz.dstOffset, isDST: true})
+	}
+	return zones
+}
+
+// transitions returns the transitions of a POSIX TZ string from
+// posixTZFirstYear to posixTZLastYear, in order. Daylight saving time starts
+// at a local time in standard time, and ends at a local time in daylight
+// saving time.
+func (tz posixTZ) transitions() []tzTransition {
+	if tz.dst == "" {
+		return nil
+	}
+	transitions := []tzTransition{}
+	for year := posixTZFirstYear; year <= posixTZLastYear; year++ {
+		start := tzTransition{at: tz.start.at(year) - int64(tz.stdOffset), zone: 1}
+		end := tzTransition{at: tz.end.at(year) - int64(tz.dstOffset), zone: 0}
+		if end.at < start.at {
+			// Southern hemisphere daylight saving time crosses the year
+			start, end = end, start
+		}
+		transitions = append(transitions, start, end)
+	}
+	return transitions
+}
+
+// at returns the local time of a rule in a year, as seconds since the Unix
+// epoch as if the local time were UTC.
+func (r posixTZRule) at(year int) int64 {
+	date := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	switch r.kind {
+	case 'J':
+		date = date.AddDate(0, 0, r.day-1)
+		if r.day >= 60 && time.Date(year, time.February, 29, 0, 0, 0, 0, time.UTC).Month() == time.February {
+			date = date.AddDate(0, 0, 1)
+		}
+	case 'N':
+		date = date.AddDate(0, 0, r.day)
+	case 'M':
+		// Week 5 is the last of the weekday in the month
+		first := time.Date(year, time.Month(r.month), 1, 0, 0, 0, 0, time.UTC)
+		date = first.AddDate(0, 0, (r.day-int(first.Weekday())+7)%7+(r.week-1)*7)
+		for date.Month() != first.Month() {
+			date = date.AddDate(0, 0, -7)
+		}
+	}
+	return date.Unix() + int64(r.time)
+}
+
+// posixTZData builds TZif data with a POSIX TZ string footer. The version 1
+// data only has standard time, the first zone, as Go only reads the version 2
+// data with 64-bit transition times.
+func posixTZData(tz string, zones []tzZone, transitions []tzTransition) []byte {
+	var b bytes.Buffer
+	writeTZifData(&b, zones[:1], nil, func(at int64) { binary.Write(&b, binary.BigEndian, int32(at)) })
+	writeTZifData(&b, zones, transitions, func(at int64) { binary.Write(&b, binary.BigEndian, at) })
+	b.WriteString("\n" + tz + "\n")
+	return b.Bytes()
+}
+
+// writeTZifData writes the header and data block of one version of TZif data,
+// writing transition times with writeTime.
+func writeTZifData(b *bytes.Buffer, zones []tzZone, transitions []tzTransition, writeTime func(int64)) {
+	abbreviations := ""
+	indexes := make([]int, len(zones))
+	for i, z := range zones {
+		if j := strings.Index(abbreviations, z.name+"\x00"); j >= 0 {
+			indexes[i] = j
+			continue
+		}
+		indexes[i] = len(abbreviations)
+		abbreviations += z.name + "\x00"
+	}
+
+	b.WriteString("TZif2")
+	b.Write(make([]byte, 15))
+	// Counts of UT/local indicators, standard/wall indicators, leap seconds,
+	// transitions, zones and abbreviation bytes
+	for _, n := range []int{0, 0, 0, len(transitions), len(zones), len(abbreviations)} {
+		binary.Write(b, binary.BigEndian, uint32(n))
+	}
+	for _, t := range transitions {
+		writeTime(t.at)
+	}
+	for _, t := range transitions {
+		b.WriteByte(byte(t.zone))
+	}
+	for i, z := range zones {
+		binary.Write(b, binary.BigEndian, int32(z.offset))
+		isDST := byte(0)
+		if z.isDST {
+			isDST = 1
+		}
+		b.Write([]byte{isDST, byte(indexes[i])})
+	}
+	b.WriteString(abbreviations)
+}
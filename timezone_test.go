@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadLocationIANA(t *testing.T) {
+	for _, name := range []string{"", "UTC", "Pacific/Auckland", "Etc/GMT-12", "EST5EDT"} {
+		loc, err := loadLocation(name)
+		if assert.NoError(t, err, name) {
+			expected, _ := time.LoadLocation(name)
+			assert.Equal(t, expected, loc)
+		}
+	}
+}
+
+func TestLoadLocationUTCOffset(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for name, offset := range map[string]int{
+		"+05:30":    5*60*60 + 30*60,
+		"UTC+05:30": 5*60*60 + 30*60,
+		"+0545":     5*60*60 + 45*60,
+		"-03":       -3 * 60 * 60,
+		"UTC-3":     -3 * 60 * 60,
+		"UTC-09:30": -(9*60*60 + 30*60),
+		"+14:00":    14 * 60 * 60,
+	} {
+		loc, err := loadLocation(name)
+		if assert.NoError(t, err, name) {
+			zone, actual := now.In(loc).Zone()
+			assert.Equal(t, offset, actual, name)
+			assert.Equal(t, name, zone)
+		}
+	}
+
+	for _, name := range []string{"+15:00", "UTC-14:30", "+05:60"} {
+		_, err := loadLocation(name)
+		assert.Equal(t, errors.New(`Invalid UTC offset. Must be between -14:00 and +14:00. Got: "`+name+`"`), err)
+	}
+}
+
+func TestLoadLocationPOSIX(t *testing.T) {
+	auckland, _ := time.LoadLocation("Pacific/Auckland")
+	loc, err := loadLocation("NZST-12NZDT,M9.5.0,M4.1.0/3")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "NZST-12NZDT,M9.5.0,M4.1.0/3", loc.String())
+
+	// Either side of both transitions in 2024
+	for _, utc := range []time.Time{
+		time.Date(2024, 4, 6, 13, 59, 0, 0, time.UTC),
+		time.Date(2024, 4, 6, 14, 0, 0, 0, time.UTC),
+		time.Date(2024, 9, 28, 13, 59, 0, 0, time.UTC),
+		time.Date(2024, 9, 28, 14, 0, 0, 0, time.UTC),
+		time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+	} {
+		assert.Equal(t, utc.In(auckland).Format(time.RFC3339+" MST"), utc.In(loc).Format(time.RFC3339+" MST"), utc)
+	}
+
+	start, end := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC).In(loc).ZoneBounds()
+	assert.Equal(t, time.Date(2024, 4, 6, 14, 0, 0, 0, time.UTC), start.UTC())
+	assert.Equal(t, time.Date(2024, 9, 28, 14, 0, 0, 0, time.UTC), end.UTC())
+
+	// Daylight saving time crosses the start of the year
+	start, end = time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC).In(loc).ZoneBounds()
+	assert.Equal(t, time.Date(2023, 9, 23, 14, 0, 0, 0, time.UTC), start.UTC())
+	assert.Equal(t, time.Date(2024, 4, 6, 14, 0, 0, 0, time.UTC), end.UTC())
+	for _, utc := range []time.Time{
+		time.Date(2023, 12, 31, 12, 0, 0, 0, time.UTC),
+		time.Date(2024, 12, 15, 0, 0, 0, 0, time.UTC),
+		time.Date(2031, 1, 1, 0, 0, 0, 0, time.UTC),
+	} {
+		expectedStart, expectedEnd := utc.In(auckland).ZoneBounds()
+		start, end = utc.In(loc).ZoneBounds()
+		assert.Equal(t, expectedStart.UTC(), start.UTC(), utc)
+		assert.Equal(t, expectedEnd.UTC(), end.UTC(), utc)
+	}
+
+	// Without rules, daylight saving time uses the US rules
+	newYork, _ := time.LoadLocation("America/New_York")
+	loc, err = loadLocation("<EST>5<EDT>")
+	if assert.NoError(t, err) {
+		for _, utc := range []time.Time{time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC), time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)} {
+			expectedStart, expectedEnd := utc.In(newYork).ZoneBounds()
+			start, end = utc.In(loc).ZoneBounds()
+			assert.Equal(t, expectedStart.UTC(), start.UTC(), utc)
+			assert.Equal(t, expectedEnd.UTC(), end.UTC(), utc)
+		}
+	}
+
+	// Without daylight saving time, and with a quoted abbreviation
+	loc, err = loadLocation("<+0530>-5:30")
+	if assert.NoError(t, err) {
+		zone, offset := time.Date(2024, 6, 1, 0, 0, 0, 0, loc).Zone()
+		assert.Equal(t, "+0530", zone)
+		assert.Equal(t, 5*60*60+30*60, offset)
+		start, end := time.Date(2024, 1, 1, 0, 0, 0, 0, loc).ZoneBounds()
+		assert.True(t, start.IsZero() && end.IsZero(), "no transitions without daylight saving time")
+	}
+}
+
+func TestLoadLocationErrors(t *testing.T) {
+	for name, expected := range map[string]string{
+		"Pacific/Aukland":              `Unknown timezone. Must be an IANA timezone such as Pacific/Auckland, a UTC offset such as +05:30 or UTC+05:30, or a POSIX TZ string such as NZST-12NZDT,M9.5.0,M4.1.0/3. Got: "Pacific/Aukland"`,
+		"NZ-12NZDT":                    `Invalid POSIX TZ string, zone abbreviations must be at least 3 letters. Must be std offset [dst [offset] [,start[/time],end[/time]]], such as NZST-12NZDT,M9.5.0,M4.1.0/3. Got: "NZ-12NZDT"`,
+		"NZST-25":                      `Invalid POSIX TZ string, expected an offset of hours from 0-24. Must be std offset [dst [offset] [,start[/time],end[/time]]], such as NZST-12NZDT,M9.5.0,M4.1.0/3. Got: "NZST-25"`,
+		"NZST-12NZDT,M13.5.0,M4.1.0/3": `Invalid POSIX TZ string, expected month rule Mm.w.d, with month from 1-12, week from 1-5 and day from 0-6. Must be std offset [dst [offset] [,start[/time],end[/time]]], such as NZST-12NZDT,M9.5.0,M4.1.0/3. Got: "NZST-12NZDT,M13.5.0,M4.1.0/3"`,
+		"NZST-12NZDT,M9.5.0":           `Invalid POSIX TZ string, expected start and end rules after a comma. Must be std offset [dst [offset] [,start[/time],end[/time]]], such as NZST-12NZDT,M9.5.0,M4.1.0/3. Got: "NZST-12NZDT,M9.5.0"`,
+		"NZST-12NZDT,J0,J100":          `Invalid POSIX TZ string, expected a Julian day from 1-365 after J. Must be std offset [dst [offset] [,start[/time],end[/time]]], such as NZST-12NZDT,M9.5.0,M4.1.0/3. Got: "NZST-12NZDT,J0,J100"`,
+	} {
+		_, err := loadLocation(name)
+		assert.Equal(t, errors.New(expected), err, name)
+	}
+}
+
+func TestLoadConfigTimezoneFormats(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "config_test.*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(f.Name(), []byte(`
+localized_timezones:
+- UTC+05:30
+- NZST-12NZDT,M9.5.0,M4.1.0/3
+time_of_use:
+- name: timezone_test
+  timezone: +05:30
+`), 0644)
+	_, err = loadConfig(f.Name())
+	assert.NoError(t, err)
+
+	os.WriteFile(f.Name(), []byte(`
+time_of_use:
+- name: timezone_test
+  timezone: UTC+25
+`), 0644)
+	_, err = loadConfig(f.Name())
+	assert.Equal(t, errors.New(`Invalid UTC offset. Must be between -14:00 and +14:00. Got: "UTC+25"`), err)
+}
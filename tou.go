@@ -3,16 +3,32 @@ package main
 import (
 	"log/slog"
 	"slices"
+	"strconv"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 )
 
-func describeTOUMetric(tou timeOfUse, now time.Time) *prometheus.Desc {
-	labels := map[string]string{"tz": "UTC"}
-	if tou.Timezone != "" {
-		labels["tz"] = tou.Timezone
+// Name of the state used when no time window matches
+const defaultWindowName = "default"
+
+// Labels added to TOU metrics which have one series per consumption tier
+var touTierLabels = []string{"tier", "tier_upper_bound"}
+
+// Label of the time window of the window active and window seconds metrics
+const touWindowLabel = "window"
+
+func describeTOUMetric(n metricNaming, tou timeOfUse, now time.Time) *prometheus.Desc {
+	if len(tou.VariableLabels) > 0 {
+		return describeStableTOUMetric(n, tou)
 	}
+
+	var variableLabels []string
+	if tw, ok := activeTimeWindow(tou, now); ok && len(tw.Tiers) > 0 {
+		variableLabels = touTierLabels
+	}
+
+	labels := n.touTimezoneLabels(tou)
 	slog.Debug("Building metric desc labels", "tou", tou.Name, "labels", labels, "step", 1)
 
 	// Set default labels from time of use
@@ -22,47 +38,301 @@ func describeTOUMetric(tou timeOfUse, now time.Time) *prometheus.Desc {
 	slog.Debug("Building metric desc labels", "tou", tou.Name, "labels", labels, "step", 2)
 
 	// Set override labels from current time window
-	for _, tw := range tou.TimeWindows {
-		if isWithinTimeWindow(tw, now) {
-			for k, v := range tw.Labels {
-				labels[k] = v
-			}
-		}
+	for k, v := range activeWindowLabels(tou, now) {
+		labels[k] = v
 	}
 	slog.Debug("Building metric desc labels", "tou", tou.Name, "labels", labels, "step", 3)
 
 	return prometheus.NewDesc(
-		tou.Name,
+		n.touMetricName(tou),
 		tou.Description,
-		nil,
+		variableLabels,
 		labels,
 	)
 }
 
+// describeStableTOUMetric builds a desc which doesn't change with the time of
+// day. Declared variable labels are left to be filled in at collection time by
+// touLabelValues, all other labels are constant.
+func describeStableTOUMetric(n metricNaming, tou timeOfUse) *prometheus.Desc {
+	variableLabels := tou.VariableLabels
+	if touHasTiers(tou) {
+		variableLabels = slices.Concat(variableLabels, touTierLabels)
+	}
+
+	return prometheus.NewDesc(
+		n.touMetricName(tou),
+		tou.Description,
+		variableLabels,
+		n.touConstLabels(tou),
+	)
+}
+
+func touHasTiers(tou timeOfUse) bool {
+	return slices.ContainsFunc(tou.TimeWindows, func(tw timeWindow) bool { return len(tw.Tiers) > 0 })
+}
+
+// touSeries is a value of a TOU metric, with its variable label values.
+type touSeries struct {
+	value       float64
+	labelValues []string
+}
+
+// touSeriesValues returns the series of a TOU metric at the given time for the
+// given value. When the active time window has tiers, there's one series per
+// tier. Stable metrics with tiers in any window always have tier labels, so
+// outside of tiered windows there's a single unbounded tier.
+func touSeriesValues(tou timeOfUse, now time.Time, value float64) []touSeries {
+	labelValues := touLabelValues(tou, now)
+
+	tw, ok := activeTimeWindow(tou, now)
+	if !ok || len(tw.Tiers) == 0 {
+		if len(tou.VariableLabels) > 0 && touHasTiers(tou) {
+			labelValues = append(labelValues, "0", "+Inf")
+		}
+		return []touSeries{{value: value, labelValues: labelValues}}
+	}
+
+	series := make([]touSeries, len(tw.Tiers))
+	for i, t := range tw.Tiers {
+		upperBound := "+Inf"
+		if t.UpTo != nil {
+			upperBound = strconv.FormatFloat(*t.UpTo, 'f', -1, 64)
+		}
+		series[i] = touSeries{
+			value:       t.Value,
+			labelValues: slices.Concat(labelValues, []string{strconv.Itoa(i), upperBound}),
+		}
+	}
+	return series
+}
+
+// describeWindowActiveMetric builds the desc of the <name>_window_active state
+// set. Only labels which don't change with the time window are included.
+func describeWindowActiveMetric(n metricNaming, tou timeOfUse) *prometheus.Desc {
+	return prometheus.NewDesc(
+		n.touMetricName(tou)+"_window_active",
+		"Whether each time window of "+tou.Name+" is active. The default state is active when no time window matches.",
+		[]string{touWindowLabel},
+		n.touConstLabels(tou),
+	)
+}
+
+// touConstLabels returns the labels of a time of use which never change with
+// the active time window.
+func (n metricNaming) touConstLabels(tou timeOfUse) map[string]string {
+	labels := n.touTimezoneLabels(tou)
+	for k, v := range tou.Labels {
+		if !slices.Contains(tou.VariableLabels, k) {
+			labels[k] = v
+		}
+	}
+	return labels
+}
+
+// touWindowStates returns the distinct time window names in config order,
+// followed by the default state.
+func touWindowStates(tou timeOfUse) []string {
+	states := []string{}
+	if tou.Source != "" {
+		states = append(states, feedWindowName)
+	}
+	for _, tw := range tou.TimeWindows {
+		if !slices.Contains(states, tw.Name) {
+			states = append(states, tw.Name)
+		}
+	}
+	return append(states, defaultWindowName)
+}
+
+// touLabelValues returns the values of the variable labels at the given time,
+// in the order they are declared. Labels not set by a matching time window
+// fall back to the time of use labels, or an empty string if unset there too.
+// Returns nil when the time of use doesn't declare variable labels.
+func touLabelValues(tou timeOfUse, now time.Time) []string {
+	if len(tou.VariableLabels) == 0 {
+		return nil
+	}
+
+	labels := map[string]string{}
+	for _, l := range tou.VariableLabels {
+		labels[l] = tou.Labels[l]
+	}
+	for k, v := range activeWindowLabels(tou, now) {
+		// Feed rows may set labels which aren't declared
+		if _, ok := labels[k]; ok {
+			labels[k] = v
+		}
+	}
+
+	values := make([]string, len(tou.VariableLabels))
+	for i, l := range tou.VariableLabels {
+		values[i] = labels[l]
+	}
+	return values
+}
+
+// currentTOUValue returns the value to report for a time of use at the given
+// time, taking the settlement period into account. Returns false when no
+// series should be reported.
+func currentTOUValue(tou timeOfUse, now time.Time) (float64, bool) {
+	if tou.SettlementPeriod > 0 {
+		return settlementValue(tou, now)
+	}
+	if _, ok := activeTimeWindow(tou, now); !ok && tou.AbsentWhenUnmatched {
+		return 0, false
+	}
+	return calculateTOUValue(tou, now), true
+}
+
 func calculateTOUValue(tou timeOfUse, now time.Time) float64 {
+	if tw, ok := activeTimeWindow(tou, now); ok {
+		return timeWindowValue(tou, tw, now)
+	}
+	return tou.DefaultValue
+}
+
+// steppedTOUValue is calculateTOUValue without ramps or points, used as the
+// values to ramp between.
+func steppedTOUValue(tou timeOfUse, now time.Time) float64 {
+	if tw, ok := activeTimeWindow(tou, now); ok {
+		return tw.Value
+	}
+	return tou.DefaultValue
+}
+
+// timeWindowValue returns the value of a time window which is active at the
+// given time, interpolating points and ramps in the location of now.
+func timeWindowValue(tou timeOfUse, tw timeWindow, now time.Time) float64 {
+	if len(tw.Points) > 0 {
+		return interpolatePoints(tw.Points, now)
+	}
+
+	start, end := timeWindowBounds(tw, now)
+	if tw.RampIn > 0 && now.Before(start.Add(tw.RampIn)) {
+		from := steppedTOUValue(tou, start.Add(-time.Nanosecond))
+		return lerp(from, tw.Value, float64(now.Sub(start))/float64(tw.RampIn))
+	}
+	if tw.RampOut > 0 && !now.Before(end.Add(-tw.RampOut)) {
+		to := steppedTOUValue(tou, end)
+		return lerp(tw.Value, to, float64(now.Sub(end.Add(-tw.RampOut)))/float64(tw.RampOut))
+	}
+	return tw.Value
+}
+
+// interpolatePoints linearly interpolates between points on the day of now.
+// Before the first point and after the last point the value is held.
+func interpolatePoints(points []rampPoint, now time.Time) float64 {
+	prev := points[0]
+	prevTime := rampPointTime(prev, now)
+	if now.Before(prevTime) {
+		return prev.Value
+	}
+	for _, p := range points[1:] {
+		t := rampPointTime(p, now)
+		if now.Before(t) {
+			return lerp(prev.Value, p.Value, float64(now.Sub(prevTime))/float64(t.Sub(prevTime)))
+		}
+		prev, prevTime = p, t
+	}
+	return prev.Value
+}
+
+func rampPointTime(p rampPoint, day time.Time) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), p.hour, p.minute, 0, 0, day.Location())
+}
+
+func lerp(from, to, fraction float64) float64 {
+	return from + (to-from)*fraction
+}
+
+// activeWindowLabels returns the labels set by the active feed row, or by all
+// time windows matching the given time.
+func activeWindowLabels(tou timeOfUse, now time.Time) map[string]string {
+	if row, ok := activeFeedRow(tou, now); ok {
+		return row.Labels
+	}
+
+	labels := map[string]string{}
 	for _, tw := range tou.TimeWindows {
 		if isWithinTimeWindow(tw, now) {
-			return tw.Value
+			for k, v := range tw.Labels {
+				labels[k] = v
+			}
 		}
 	}
-	return tou.DefaultValue
+	return labels
+}
+
+// activeFeedRow returns the feed row covering the given time, if the time of
+// use has a source.
+func activeFeedRow(tou timeOfUse, now time.Time) (feedRow, bool) {
+	if tou.Source == "" {
+		return feedRow{}, false
+	}
+	return feedRowAt(tou.Name, now)
+}
+
+// activeTimeWindow returns the first time window matching the given time. A
+// feed row covering the given time takes precedence over time windows, and is
+// returned as a time window named "feed".
+func activeTimeWindow(tou timeOfUse, now time.Time) (timeWindow, bool) {
+	if row, ok := activeFeedRow(tou, now); ok {
+		return timeWindow{Name: feedWindowName, Value: row.Value, Labels: row.Labels}, true
+	}
+	for _, tw := range tou.TimeWindows {
+		if isWithinTimeWindow(tw, now) {
+			return tw, true
+		}
+	}
+	return timeWindow{}, false
+}
+
+// activeWindowName returns the name of the active time window, or the default
+// state name when no time window matches.
+func activeWindowName(tou timeOfUse, now time.Time) string {
+	if tw, ok := activeTimeWindow(tou, now); ok {
+		return tw.Name
+	}
+	return defaultWindowName
 }
 
 func isWithinTimeWindow(tw timeWindow, now time.Time) bool {
 	if len(tw.Days) > 0 && !slices.Contains(tw.Days, int(now.Weekday())) {
 		return false
 	}
+	if len(tw.Months) > 0 && !slices.Contains(tw.Months, int(now.Month())) {
+		return false
+	}
+
+	start, end := timeWindowBounds(tw, now)
+	return !now.Before(start) && now.Before(end)
+}
 
-	start := time.Date(now.Year(), now.Month(), now.Day(), tw.startHour, tw.startMinute, 0, 0, now.Location())
-	end := time.Date(now.Year(), now.Month(), now.Day(), tw.endHour, tw.endMinute, 0, 0, now.Location())
+// timeWindowBounds returns the start and end of a time window on the day of
+// the given time, in its location.
+func timeWindowBounds(tw timeWindow, day time.Time) (time.Time, time.Time) {
+	start := time.Date(day.Year(), day.Month(), day.Day(), tw.startHour, tw.startMinute, 0, 0, day.Location())
+	end := time.Date(day.Year(), day.Month(), day.Day(), tw.endHour, tw.endMinute, 0, 0, day.Location())
 
 	// Handle setting end time to midnight
 	if tw.End == "00:00" || tw.End == "24:00" {
 		end = end.AddDate(0, 0, 1)
 	}
 
-	if now.Equal(start) || now.After(start) && now.Before(end) {
-		return true
+	// Solar events are recalculated for each day. Windows relative to an event
+	// which doesn't happen are empty, whatever their offsets
+	if tw.startSolar != nil {
+		var ok bool
+		if start, ok = tw.startSolar.time(day); !ok {
+			return start, start
+		}
+	}
+	if tw.endSolar != nil {
+		var ok bool
+		if end, ok = tw.endSolar.time(day); !ok {
+			return end, end
+		}
 	}
-	return false
+	return start, end
 }
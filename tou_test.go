@@ -111,6 +111,38 @@ func TestDescribeTOUMetric(t *testing.T) {
 				nil, map[string]string{"tz": "UTC"},
 			),
 		},
+		"variable labels": {
+			inputTou: timeOfUse{
+				Name:           "variable_labels",
+				Description:    "variable labels description",
+				Labels:         map[string]string{"provider": "Power Co", "rate": "Night"},
+				VariableLabels: []string{"rate"},
+				TimeWindows: []timeWindow{{
+					startHour: 11,
+					endHour:   13,
+					Labels:    map[string]string{"rate": "Peak"},
+				}},
+			},
+			expectedDesc: prometheus.NewDesc(
+				"variable_labels", "variable labels description",
+				[]string{"rate"}, map[string]string{"tz": "UTC", "provider": "Power Co"},
+			),
+		},
+		"tiers": {
+			inputTou: timeOfUse{
+				Name:        "tiers",
+				Description: "tiers description",
+				TimeWindows: []timeWindow{{
+					startHour: 11,
+					endHour:   13,
+					Tiers:     []tier{{UpTo: ptr(8.0), Value: 0.2}, {Value: 0.3}},
+				}},
+			},
+			expectedDesc: prometheus.NewDesc(
+				"tiers", "tiers description",
+				[]string{"tier", "tier_upper_bound"}, map[string]string{"tz": "UTC"},
+			),
+		},
 		"day of week nil": {
 			inputTou: timeOfUse{
 				Name:        "dow_filter_nil",
@@ -135,6 +167,7 @@ func TestDescribeTOUMetric(t *testing.T) {
 			t.Fatal("error loading timezone required for test", "err", err)
 		}
 		v := describeTOUMetric(
+			metricNaming{},
 			tc.inputTou,
 			time.Date(2023, 12, 13, 12, 00, 00, 00, loc),
 		)
@@ -142,6 +175,37 @@ func TestDescribeTOUMetric(t *testing.T) {
 	}
 }
 
+func TestTOULabelValues(t *testing.T) {
+	tou := timeOfUse{
+		Name:           "test",
+		Labels:         map[string]string{"provider": "Power Co", "rate": "Night"},
+		VariableLabels: []string{"rate", "season"},
+		TimeWindows: []timeWindow{
+			{
+				startHour: 7,
+				endHour:   11,
+				Labels:    map[string]string{"rate": "Peak"},
+			},
+			{
+				startHour: 7,
+				endHour:   21,
+				Labels:    map[string]string{"season": "Summer"},
+			},
+		},
+	}
+
+	assert.Equal(t, []string{"Night", ""}, touLabelValues(tou, time.Date(2023, 12, 1, 6, 0, 0, 0, time.UTC)),
+		"should use default labels outside of time windows")
+	assert.Equal(t, []string{"Peak", "Summer"}, touLabelValues(tou, time.Date(2023, 12, 1, 8, 0, 0, 0, time.UTC)),
+		"should merge labels from all matching time windows")
+	assert.Equal(t, []string{"Night", "Summer"}, touLabelValues(tou, time.Date(2023, 12, 1, 12, 0, 0, 0, time.UTC)),
+		"should fall back to defaults for labels unset by the matching time window")
+
+	tou.VariableLabels = nil
+	assert.Nil(t, touLabelValues(tou, time.Date(2023, 12, 1, 8, 0, 0, 0, time.UTC)),
+		"should return nil without variable labels")
+}
+
 func TestCalculateTOUValue(t *testing.T) {
 	assert.Equal(t, float64(123), calculateTOUValue(
 		timeOfUse{
@@ -324,3 +388,122 @@ func TestIsWIthinTimeWindow(t *testing.T) {
 		time.Date(2023, 12, 13, 12, 0, 0, 0, time.UTC),
 	), "Check Day of week filter for a day hit if nil")
 }
+
+func TestWindowStates(t *testing.T) {
+	tou := timeOfUse{
+		Name:               "test",
+		WindowActiveMetric: true,
+		TimeWindows: []timeWindow{
+			{Name: "peak", startHour: 7, endHour: 11},
+			{Name: "shoulder", startHour: 11, endHour: 17},
+			{Name: "peak", startHour: 17, endHour: 21},
+		},
+	}
+
+	assert.Equal(t, []string{"peak", "shoulder", "default"}, touWindowStates(tou))
+	assert.Equal(t, "peak", activeWindowName(tou, time.Date(2023, 12, 1, 18, 0, 0, 0, time.UTC)))
+	assert.Equal(t, "shoulder", activeWindowName(tou, time.Date(2023, 12, 1, 11, 0, 0, 0, time.UTC)))
+	assert.Equal(t, "default", activeWindowName(tou, time.Date(2023, 12, 1, 22, 0, 0, 0, time.UTC)))
+}
+
+func TestCalculateTOUValueRamps(t *testing.T) {
+	tou := timeOfUse{
+		Name:         "setpoint",
+		DefaultValue: 16,
+		TimeWindows: []timeWindow{
+			{
+				Value:     20,
+				Start:     "07:00",
+				End:       "09:00",
+				startHour: 7,
+				endHour:   9,
+				RampIn:    30 * time.Minute,
+				RampOut:   time.Hour,
+			},
+			{
+				Value:     18,
+				Start:     "09:00",
+				End:       "17:00",
+				startHour: 9,
+				endHour:   17,
+			},
+		},
+	}
+
+	testCases := map[string]struct {
+		now      time.Time
+		expected float64
+	}{
+		"before ramp in":     {time.Date(2023, 12, 1, 6, 59, 0, 0, time.UTC), 16},
+		"start of ramp in":   {time.Date(2023, 12, 1, 7, 0, 0, 0, time.UTC), 16},
+		"middle of ramp in":  {time.Date(2023, 12, 1, 7, 15, 0, 0, time.UTC), 18},
+		"end of ramp in":     {time.Date(2023, 12, 1, 7, 30, 0, 0, time.UTC), 20},
+		"start of ramp out":  {time.Date(2023, 12, 1, 8, 0, 0, 0, time.UTC), 20},
+		"middle of ramp out": {time.Date(2023, 12, 1, 8, 30, 0, 0, time.UTC), 19},
+		"next window":        {time.Date(2023, 12, 1, 9, 0, 0, 0, time.UTC), 18},
+	}
+	for name, tc := range testCases {
+		assert.InDelta(t, tc.expected, calculateTOUValue(tou, tc.now), 1e-9, name)
+	}
+}
+
+func TestCalculateTOUValuePoints(t *testing.T) {
+	tou := timeOfUse{
+		Name:         "dimming",
+		DefaultValue: 0,
+		TimeWindows: []timeWindow{{
+			Start:     "18:00",
+			End:       "00:00",
+			startHour: 18,
+			Points: []rampPoint{
+				{Time: "19:00", Value: 100, hour: 19},
+				{Time: "22:00", Value: 40, hour: 22},
+			},
+		}},
+	}
+
+	assert.Equal(t, 100.0, calculateTOUValue(tou, time.Date(2023, 12, 1, 18, 30, 0, 0, time.UTC)), "should hold the first point")
+	assert.Equal(t, 80.0, calculateTOUValue(tou, time.Date(2023, 12, 1, 20, 0, 0, 0, time.UTC)), "should interpolate between points")
+	assert.Equal(t, 40.0, calculateTOUValue(tou, time.Date(2023, 12, 1, 23, 0, 0, 0, time.UTC)), "should hold the last point")
+	assert.Equal(t, 0.0, calculateTOUValue(tou, time.Date(2023, 12, 1, 17, 0, 0, 0, time.UTC)), "should use the default outside the window")
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestTOUSeriesValues(t *testing.T) {
+	tou := timeOfUse{
+		Name:         "tiered",
+		DefaultValue: 0.1,
+		TimeWindows: []timeWindow{{
+			startHour: 7,
+			endHour:   9,
+			Labels:    map[string]string{"rate": "Peak"},
+			Tiers:     []tier{{UpTo: ptr(8.0), Value: 0.2}, {UpTo: ptr(12.5), Value: 0.25}, {Value: 0.3}},
+		}},
+	}
+
+	assert.Equal(t, []touSeries{
+		{value: 0.2, labelValues: []string{"0", "8"}},
+		{value: 0.25, labelValues: []string{"1", "12.5"}},
+		{value: 0.3, labelValues: []string{"2", "+Inf"}},
+	}, touSeriesValues(tou, time.Date(2023, 12, 1, 8, 0, 0, 0, time.UTC), 0.2), "should have a series per tier")
+	assert.Equal(t, []touSeries{{value: 0.1}}, touSeriesValues(tou, time.Date(2023, 12, 1, 10, 0, 0, 0, time.UTC), 0.1),
+		"should have a single series without tiers")
+
+	tou.Labels = map[string]string{"rate": "Off-peak"}
+	tou.VariableLabels = []string{"rate"}
+	assert.Equal(t, []touSeries{
+		{value: 0.2, labelValues: []string{"Peak", "0", "8"}},
+		{value: 0.25, labelValues: []string{"Peak", "1", "12.5"}},
+		{value: 0.3, labelValues: []string{"Peak", "2", "+Inf"}},
+	}, touSeriesValues(tou, time.Date(2023, 12, 1, 8, 0, 0, 0, time.UTC), 0.2), "should append tier labels to variable labels")
+	assert.Equal(t, []touSeries{{value: 0.1, labelValues: []string{"Off-peak", "0", "+Inf"}}},
+		touSeriesValues(tou, time.Date(2023, 12, 1, 10, 0, 0, 0, time.UTC), 0.1),
+		"stable metrics should keep tier labels without tiers")
+	assert.Equal(t,
+		prometheus.NewDesc("tiered", "", []string{"rate", "tier", "tier_upper_bound"}, map[string]string{"tz": "UTC"}),
+		describeTOUMetric(metricNaming{}, tou, time.Date(2023, 12, 1, 10, 0, 0, 0, time.UTC)),
+	)
+}
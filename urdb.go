@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+)
+
+// urdbTariff is the subset of an OpenEI Utility Rate Database tariff used to
+// build time windows.
+// https://openei.org/services/doc/rest/util_rates/?version=7
+type urdbTariff struct {
+	Name                  string       `json:"name"`
+	Utility               string       `json:"utility"`
+	EnergyWeekdaySchedule [][]int      `json:"energyweekdayschedule"`
+	EnergyWeekendSchedule [][]int      `json:"energyweekendschedule"`
+	EnergyRateStructure   [][]urdbRate `json:"energyratestructure"`
+}
+
+type urdbRate struct {
+	Rate float64  `json:"rate"`
+	Adj  float64  `json:"adj"`
+	Max  *float64 `json:"max,omitempty"`
+	Unit string   `json:"unit"`
+}
+
+// loadURDBFile reads a URDB tariff from either an API response, in which case
+// the first item is used, or a single tariff object.
+func loadURDBFile(path string) (urdbTariff, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return urdbTariff{}, err
+	}
+
+	resp := struct {
+		Items []urdbTariff `json:"items"`
+	}{}
+	if err := json.Unmarshal(b, &resp); err != nil {
+		return urdbTariff{}, err
+	}
+	if len(resp.Items) > 0 {
+		return resp.Items[0], nil
+	}
+
+	t := urdbTariff{}
+	err = json.Unmarshal(b, &t)
+	return t, err
+}
+
+// urdbTimeWindows converts the energy schedules of a URDB tariff into time
+// windows, one per run of hours in the same period. Windows are named after
+// their period, and use the tier 0 rate including adjustments. Windows which
+// only differ by month are merged.
+func urdbTimeWindows(t urdbTariff) ([]timeWindow, error) {
+	if len(t.EnergyRateStructure) == 0 {
+		return nil, errors.New("URDB tariff has no energyratestructure")
+	}
+	for period, tiers := range t.EnergyRateStructure {
+		if len(tiers) == 0 {
+			return nil, fmt.Errorf("URDB tariff period %d has no rate tiers", period)
+		}
+	}
+
+	schedules := []struct {
+		name     string
+		schedule [][]int
+		days     []int
+	}{
+		{"energyweekdayschedule", t.EnergyWeekdaySchedule, []int{1, 2, 3, 4, 5}},
+		{"energyweekendschedule", t.EnergyWeekendSchedule, []int{0, 6}},
+	}
+
+	windows := []timeWindow{}
+	for _, s := range schedules {
+		if len(s.schedule) != 12 {
+			return nil, fmt.Errorf("URDB %s must have 12 months. Got: %d", s.name, len(s.schedule))
+		}
+		for month, hours := range s.schedule {
+			if len(hours) != 24 {
+				return nil, fmt.Errorf("URDB %s month %d must have 24 hours. Got: %d", s.name, month+1, len(hours))
+			}
+
+			for start := 0; start < 24; {
+				period := hours[start]
+				if period < 0 || period >= len(t.EnergyRateStructure) {
+					return nil, fmt.Errorf("URDB %s month %d hour %d has unknown period %d", s.name, month+1, start, period)
+				}
+				end := start + 1
+				for end < 24 && hours[end] == period {
+					end++
+				}
+
+				tier := t.EnergyRateStructure[period][0]
+				windows = mergeURDBWindow(windows, timeWindow{
+					Name:   fmt.Sprintf("period_%d", period),
+					Value:  tier.Rate + tier.Adj,
+					Start:  formatWindowTime(start * 60),
+					End:    formatWindowTime(end * 60),
+					Days:   s.days,
+					Months: []int{month + 1},
+				})
+				start = end
+			}
+		}
+	}
+	return windows, nil
+}
+
+// mergeURDBWindow adds the window's months to an existing window which only
+// differs by month, or appends it when there is none.
+func mergeURDBWindow(windows []timeWindow, tw timeWindow) []timeWindow {
+	for i, w := range windows {
+		if w.Name == tw.Name && w.Start == tw.Start && w.End == tw.End && w.Value == tw.Value && slices.Equal(w.Days, tw.Days) {
+			windows[i].Months = slices.Concat(w.Months, tw.Months)
+			return windows
+		}
+	}
+	return append(windows, tw)
+}
+
+// importTimeWindows loads the time windows of an import directive.
+func importTimeWindows(i tariffImport) ([]timeWindow, error) {
+	switch i.Format {
+	case "urdb":
+		t, err := loadURDBFile(i.Path)
+		if err != nil {
+			return nil, err
+		}
+		return urdbTimeWindows(t)
+	}
+	return nil, fmt.Errorf(`Unknown import format "%s". Must be urdb`, i.Format)
+}
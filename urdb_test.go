@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+// assertURDBRoundTrip checks every hour of a year matches the tier 0 rate of
+// the URDB schedule.
+func assertURDBRoundTrip(t *testing.T, tariff urdbTariff, tou timeOfUse) {
+	// 2024-01-01 is a Monday
+	for day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC); day.Year() == 2024; day = day.AddDate(0, 0, 1) {
+		schedule := tariff.EnergyWeekdaySchedule
+		if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+			schedule = tariff.EnergyWeekendSchedule
+		}
+		for hour := 0; hour < 24; hour++ {
+			period := schedule[day.Month()-1][hour]
+			rate := tariff.EnergyRateStructure[period][0]
+			now := day.Add(time.Duration(hour)*time.Hour + 30*time.Minute)
+			if !assert.InDelta(t, rate.Rate+rate.Adj, calculateTOUValue(tou, now), 1e-9, now.String()) {
+				return
+			}
+		}
+	}
+}
+
+func TestURDBTimeWindows(t *testing.T) {
+	tariff, err := loadURDBFile("urdb_test.json")
+	require.NoError(t, err)
+	assert.Equal(t, "Residential Time of Use", tariff.Name)
+
+	windows, err := urdbTimeWindows(tariff)
+	require.NoError(t, err)
+	assert.Len(t, windows, 8, "windows should be merged across months")
+
+	c, err := loadConfigFromWindows(t, windows)
+	require.NoError(t, err)
+	assertURDBRoundTrip(t, tariff, c.TimeOfUse[0])
+}
+
+// loadConfigFromWindows parses the window times of converted windows with
+// loadConfig, by writing them to a config file.
+func loadConfigFromWindows(t *testing.T, windows []timeWindow) (config, error) {
+	b, err := yaml.Marshal(config{TimeOfUse: []timeOfUse{{Name: "urdb_test", TimeWindows: windows}}})
+	require.NoError(t, err)
+	f, err := os.CreateTemp(t.TempDir(), "config_test.*.yaml")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(f.Name(), b, 0644))
+	return loadConfig(f.Name())
+}
+
+func TestURDBTimeWindowsValidation(t *testing.T) {
+	month := make([]int, 24)
+	year := make([][]int, 12)
+	for i := range year {
+		year[i] = month
+	}
+	rates := [][]urdbRate{{{Rate: 0.1}}}
+
+	testCases := map[string]struct {
+		input urdbTariff
+		err   error
+	}{
+		"no rates": {
+			input: urdbTariff{EnergyWeekdaySchedule: year, EnergyWeekendSchedule: year},
+			err:   errors.New("URDB tariff has no energyratestructure"),
+		},
+		"no tiers": {
+			input: urdbTariff{EnergyWeekdaySchedule: year, EnergyWeekendSchedule: year, EnergyRateStructure: [][]urdbRate{{}}},
+			err:   errors.New("URDB tariff period 0 has no rate tiers"),
+		},
+		"missing months": {
+			input: urdbTariff{EnergyWeekdaySchedule: year[:11], EnergyWeekendSchedule: year, EnergyRateStructure: rates},
+			err:   errors.New("URDB energyweekdayschedule must have 12 months. Got: 11"),
+		},
+		"missing hours": {
+			input: urdbTariff{EnergyWeekdaySchedule: year, EnergyWeekendSchedule: append([][]int{month[:23]}, year[1:]...), EnergyRateStructure: rates},
+			err:   errors.New("URDB energyweekendschedule month 1 must have 24 hours. Got: 23"),
+		},
+		"unknown period": {
+			input: urdbTariff{EnergyWeekdaySchedule: append([][]int{append([]int{1}, month[1:]...)}, year[1:]...), EnergyWeekendSchedule: year, EnergyRateStructure: rates},
+			err:   errors.New("URDB energyweekdayschedule month 1 hour 0 has unknown period 1"),
+		},
+	}
+
+	for name, tc := range testCases {
+		_, err := urdbTimeWindows(tc.input)
+		assert.Equal(t, tc.err, err, name)
+	}
+}
+
+func TestImportURDBCommand(t *testing.T) {
+	var out bytes.Buffer
+	code := runCommand([]string{"import-urdb", "-name", "urdb_price", "-timezone", "America/Los_Angeles", "urdb_test.json"}, &out)
+	require.Equal(t, 0, code)
+
+	f, err := os.CreateTemp(t.TempDir(), "config_test.*.yaml")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(f.Name(), out.Bytes(), 0644))
+	c, err := loadConfig(f.Name())
+	require.NoError(t, err)
+
+	tou := c.TimeOfUse[0]
+	assert.Equal(t, "urdb_price", tou.Name)
+	assert.Equal(t, "America/Los_Angeles", tou.Timezone)
+	assert.Equal(t, "Example Electric Co Residential Time of Use", tou.Description)
+
+	tariff, err := loadURDBFile("urdb_test.json")
+	require.NoError(t, err)
+	assertURDBRoundTrip(t, tariff, tou)
+
+	assert.Equal(t, 2, runCommand([]string{"import-urdb"}, &out), "should require a file")
+	assert.Equal(t, 2, runCommand([]string{"unknown"}, &out))
+}
+
+func TestImportDirective(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "config_test.*.yaml")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(f.Name(), []byte(`
+time_of_use:
+- name: urdb_price
+  import:
+    format: urdb
+    path: urdb_test.json
+`), 0644))
+
+	c, err := loadConfig(f.Name())
+	require.NoError(t, err)
+	tariff, err := loadURDBFile("urdb_test.json")
+	require.NoError(t, err)
+	assertURDBRoundTrip(t, tariff, c.TimeOfUse[0])
+}